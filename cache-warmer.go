@@ -1,198 +1,376 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"reflect"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/fatih/color"
 
 	"github.com/lettland/cache-warmer/structs"
 	"github.com/lettland/cache-warmer/symfony"
+	"github.com/lettland/cache-warmer/watcher"
 )
 
 var version = "nightly"
 
 const (
-	repository = "https://github.com/lettland/cache-warmer"
+	repository      = "https://github.com/lettland/cache-warmer"
+	outputTailLines = 15 // Lines of console output printed when a command fails
+	maxQuietPeriods = 5  // Debounce periods after which a burst of changes is handled, even if events keep coming
 )
 
 // MainLoop continuously monitors for file changes and performs cache warming if an update is detected.
 // It takes a `config` parameter of type `structs.Config` which holds the configuration values for the application.
-// It also takes a `filesToWatch` parameter of type map[string]string that represents the files to watch for changes.
-// The function checks for updated files using `symfony.GetWatchMap` and compares it with the existing `filesToWatch` map.
-// If there are any differences, it starts cache warming by calling `symfony.CacheWarmup`. It measures the time taken
-// to warm up the cache and prints the result. The updated `filesToWatch` map is then assigned to `filesToWatch`.
-// If there are no differences, the function sleeps for a specified duration defined in the `config` parameter.
-func MainLoop(config structs.Config, filesToWatch map[string]string) {
+// It also takes a `filesToWatch` snapshot that represents the files to watch for changes,
+// and the `fsWatcher` notifying that something may have changed on the filesystem.
+// On each event, the function checks for updated files using `symfony.GetWatchMap` and compares it with the existing
+// `filesToWatch` snapshot using `symfony.Diff`. If there are any differences, it waits for the changes to settle with
+// `CollectChanges`, prints the changed files and starts cache warming in the background with `StartWarmup`.
+// A failed scan is printed as a warning, once until a scan succeeds again, and its changes are ignored: the
+// next event compares a new scan with the same `filesToWatch` snapshot. A scan skipping unreadable files is
+// printed as a warning the same way, but its snapshot is used.
+// The settled snapshot, taken when the warmup starts, becomes `filesToWatch` for the next comparison, as does
+// a scan finding no change, so that the files only touched are not hashed again on each scan.
+// The watcher keeps collecting changes while the warmup runs: depending on `config.WarmupPolicy`, changes seen
+// during a warmup either queue a single follow-up warmup or kill the running one and start over.
+// With the meta strategy, the watch set is read again once a warmup is done, without comparing it with
+// `filesToWatch`, as the files listed by the Symfony cache may have changed.
+// Each warmup started and completed is recorded in the `status` file, which may be nil.
+// The loop returns once ctx is canceled, after killing the running warmup and closing the watcher, or once the
+// watcher closes its Events channel, after killing the running warmup as well.
+func MainLoop(ctx context.Context, config structs.Config, filesToWatch symfony.Snapshot, fsWatcher watcher.Watcher, status *StatusFile) {
+	var (
+		running *Warmup // The warmup in progress, nil when idle
+		pending bool    // Whether files changed during the running warmup
+		scanErr string  // The error of the last failed or partial scan, printed once
+	)
+
+	// scanFailed reports whether the scan failed, printing the error unless it was already printed. A scan
+	// returning a snapshot along with the error skipped some files, but did not fail.
+	scanFailed := func(snapshot symfony.Snapshot, err error) bool {
+		if err == nil {
+			scanErr = ""
+			return false
+		}
+		if err.Error() != scanErr {
+			scanErr = err.Error()
+			if snapshot != nil {
+				PrintWarning(fmt.Errorf("files skipped, their changes are ignored: %w", err))
+			} else {
+				PrintWarning(fmt.Errorf("scan failed, changes ignored until a scan succeeds: %w", err))
+			}
+		}
+		return snapshot == nil
+	}
+
+	startWarmup := func() {
+		running = StartWarmup(ctx, config)
+		status.Update(func(s *Status) {
+			s.State = StateWarming
+			s.Warmups++
+			s.FilesWatched = len(filesToWatch)
+			s.Watcher = WatcherKind(fsWatcher)
+			s.LastWarmup = &WarmupStatus{Started: running.Start}
+		})
+	}
+
+	// stopWarmup kills the running warmup, if any, and waits for it to return
+	stopWarmup := func() {
+		if running != nil {
+			running.Cancel()
+			<-running.Done
+		}
+	}
+
 	for {
-		updatedFiles, _ := symfony.GetWatchMap(config)
-		if !reflect.DeepEqual(filesToWatch, updatedFiles) {
-			start := time.Now()
-			fmt.Println()
-			fmt.Println(fmt.Sprintf(" > %s at %s > refreshing cache", color.New(color.FgHiYellow).Sprintf("Update detected"), color.New(color.FgGreen).Sprintf(start.Format("15:04:05"))))
-			_, _ = symfony.CacheWarmup(config)
-			end := time.Now()
-			elapsed := end.Sub(start)
-			fmt.Println(fmt.Sprintf(" > %s in %s", color.New(color.FgGreen).Sprintf("Done"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+		var done <-chan error
+		if running != nil {
+			done = running.Done
+		}
+
+		select {
+		case <-ctx.Done():
+			stopWarmup()
+			_ = fsWatcher.Close()
+			return
+		case _, ok := <-fsWatcher.Events():
+			if !ok {
+				stopWarmup()
+				return
+			}
+
+			updatedFiles, err := symfony.GetWatchMap(config, filesToWatch)
+			if scanFailed(updatedFiles, err) {
+				continue
+			}
+			if symfony.Diff(filesToWatch, updatedFiles).IsEmpty() {
+				// Keep the states of the touched files, so that their content is not hashed again on each scan
+				filesToWatch = updatedFiles
+				continue
+			}
+
+			updatedFiles, err = CollectChanges(ctx, config, fsWatcher, updatedFiles)
+			if ctx.Err() != nil || scanFailed(updatedFiles, err) {
+				continue
+			}
+			changes := symfony.Diff(filesToWatch, updatedFiles)
 			filesToWatch = updatedFiles
+			if changes.IsEmpty() {
+				continue
+			}
+
+			fsWatcher = SyncWatcher(config, fsWatcher)
+
+			fmt.Println()
+			action := "refreshing cache"
+			if running != nil {
+				action = "warmup queued"
+				if config.WarmupPolicy == structs.PolicyRestart {
+					action = "restarting warmup"
+					running.Cancel()
+				}
+			}
+			fmt.Println(fmt.Sprintf(" > %s at %s > %s", color.New(color.FgHiYellow).Sprintf("Update detected"), color.New(color.FgGreen).Sprintf(time.Now().Format("15:04:05")), action))
+			for _, line := range FormatChanges(config.DirSymfonyProject, changes, config.ChangesLimit) {
+				fmt.Println(line)
+			}
+
+			if running != nil {
+				pending = true
+				continue
+			}
+			startWarmup()
+		case err := <-done:
+			elapsed := time.Since(running.Start)
+			result := WarmupStatus{Started: running.Start, Duration: elapsed, Result: "done"}
+			switch {
+			case running.Canceled():
+				result.Result = "canceled"
+				fmt.Println(fmt.Sprintf(" > %s after %s", color.New(color.FgHiYellow).Sprintf("Canceled"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+			case err != nil:
+				result.Result, result.Error = "failed", err.Error()
+				fmt.Println(fmt.Sprintf(" > %s after %s", color.New(color.FgHiRed).Sprintf("Failed"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+				PrintCommandError(err)
+			default:
+				fmt.Println(fmt.Sprintf(" > %s in %s", color.New(color.FgGreen).Sprintf("Done"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+			}
+			running = nil
+			status.Update(func(s *Status) {
+				s.State = StateIdle
+				s.LastWarmup = &result
+			})
+
+			// The warmup rewrote the .meta files the watch set is read from
+			if config.Strategy == structs.StrategyMeta && result.Result == "done" {
+				if refreshedFiles, err := symfony.GetWatchMap(config, filesToWatch); !scanFailed(refreshedFiles, err) {
+					filesToWatch = refreshedFiles
+					fsWatcher = SyncWatcher(config, fsWatcher)
+				}
+			}
+
+			if pending {
+				pending = false
+				fmt.Println(fmt.Sprintf(" > %s > refreshing cache", color.New(color.FgHiYellow).Sprintf("Files changed during the warmup")))
+				startWarmup()
+				continue
+			}
+
 			fmt.Println(fmt.Sprintf(" > %s file(s) watched at %s", color.YellowString("%d", len(filesToWatch)), color.YellowString("%s", config.DirSymfonyProject)))
 			fmt.Println(fmt.Sprintf(" > %s to stop watching or run %s %s.", color.GreenString("CTRL+C"), color.GreenString("kill -9"), color.GreenString("%d", os.Getpid())))
-		} else {
-			time.Sleep(config.SleepTime)
 		}
 	}
 }
 
-func FormatDuration(ms int64) string {
-	const (
-		msInSecond = 1000
-		msInMinute = 60000
-	)
-
-	minutes := ms / msInMinute
-	ms = ms % msInMinute
-	seconds := ms / msInSecond
-	ms = ms % msInSecond
+// Warmup is a cache warmup running in the background.
+type Warmup struct {
+	Start    time.Time
+	Done     <-chan error // Receives the result of symfony.CacheWarmup once it returns
+	cancel   context.CancelFunc
+	canceled atomic.Bool
+}
 
-	var result []string
+// StartWarmup runs symfony.CacheWarmup in a new goroutine and returns immediately.
+// Canceling the parent ctx kills the warmup as well.
+func StartWarmup(parent context.Context, config structs.Config) *Warmup {
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan error, 1)
 
-	if minutes > 0 {
-		result = append(result, fmt.Sprintf("%d minute(s)", minutes))
-	}
-	if seconds > 0 {
-		result = append(result, fmt.Sprintf("%d second(s)", seconds))
-	}
-	if ms > 0 || len(result) == 0 {
-		result = append(result, fmt.Sprintf("%d millisecond(s)", ms))
-	}
+	go func() {
+		defer cancel()
+		_, err := symfony.CacheWarmup(ctx, config)
+		done <- err
+	}()
 
-	return strings.Join(result, ", ")
+	return &Warmup{Start: time.Now(), Done: done, cancel: cancel}
 }
 
-// main is the entry point of the program. It initializes the configuration, displays a Welcome message,
-// parses command line arguments, checks for required parameters, sets configuration values based on the command line arguments,
-// checks for the existence of Symfony console, retrieves the Symfony version, gets the files to watch,
-// displays some information about the project, and enters the main loop to monitor and react to file changes.
-func main() {
-	var config structs.Config
-	var err error
-	config.Init()
-
-	fmt.Println()
-
-	env := flag.String("env", "dev", "pass --env=env to the symfony console (default: dev)")
-	noDebug := flag.Bool("no-debug", false, "pass --no-debug to the symfony console (default: false)")
-	clearCache := flag.Bool("cache", false, "clear cache instead of just warmup (default: false)")
-	forceClearCache := flag.Bool("force", false, "force clear cache (rm -rf var/cache) (default: false)")
-	exclude := flag.String("exclude", "", "comma-separated directories not to watch")
-	vendors := flag.String("vendor", "", "comma-separated list of vendors to watch")
+// Cancel kills the running console command and skips the remaining warmup steps.
+func (w *Warmup) Cancel() {
+	w.canceled.Store(true)
+	w.cancel()
+}
 
-	pools := structs.NewCustomFlag()
-	flag.Var(pools, "pools", "comma-separated list of pools to clear")
+// Canceled reports whether the warmup was stopped by Cancel.
+func (w *Warmup) Canceled() bool {
+	return w.canceled.Load()
+}
 
-	flag.Usage = func() {
-		_, _ = fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-		flag.PrintDefaults()
+// CollectChanges merges a burst of changes into a single snapshot. Starting from the `updatedFiles` snapshot in
+// which a change was first seen, it waits for the watcher to stay quiet during `config.Debounce`, then rescans the
+// watch set. The files are considered settled once two consecutive scans are identical, meaning that no file
+// kept growing or changing in between; otherwise the quiet period starts over. The whole wait is capped at
+// `maxQuietPeriods` debounce periods, so that a file changing all the time cannot delay the warmup forever, and
+// stops as soon as ctx is canceled. A zero debounce disables the wait, but the watch set is still rescanned once
+// after `structs.GracePeriod`.
+// As the caller compares the returned snapshot with the one preceding the burst, a file that appears and
+// disappears within the window, such as an editor temporary file, never counts as a change.
+// It returns the error of a failed rescan, along with a nil snapshot as the changes must be ignored, or the
+// *symfony.ScanError of a rescan skipping unreadable files, along with its snapshot.
+func CollectChanges(ctx context.Context, config structs.Config, fsWatcher watcher.Watcher, updatedFiles symfony.Snapshot) (symfony.Snapshot, error) {
+	if config.Debounce <= 0 {
+		select {
+		case <-ctx.Done():
+			return updatedFiles, nil
+		case <-time.After(structs.GracePeriod):
+		}
+		return symfony.GetWatchMap(config, updatedFiles)
 	}
 
-	flag.Parse()
+	deadline := time.Now().Add(maxQuietPeriods * config.Debounce)
+	for {
+		if !watcher.WaitQuiet(ctx, fsWatcher, config.Debounce, time.Until(deadline)) {
+			return updatedFiles, nil
+		}
 
-	if len(os.Args) < 2 {
-		flag.Usage()
-		os.Exit(1)
+		settledFiles, err := symfony.GetWatchMap(config, updatedFiles)
+		if settledFiles == nil {
+			return nil, err
+		}
+		if symfony.Diff(updatedFiles, settledFiles).IsEmpty() || time.Now().After(deadline) {
+			return settledFiles, err
+		}
+		updatedFiles = settledFiles
 	}
+}
 
-	clickableVersion := fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", GenerateVersionLink(version), version)
-	fmt.Println(fmt.Sprintf(" > Version: %s", color.New(color.FgHiYellow).Sprintf(clickableVersion)))
+// FormatChanges returns one line per changed file, prefixed by "+" for added, "~" for modified and "-" for
+// deleted files. Paths are shown relative to the project directory. Once limit lines are reached, the remaining
+// files are summarized in a last line. A limit lower than 1 disables the truncation.
+func FormatChanges(projectDir string, changes symfony.Changes, limit int) []string {
+	var lines []string
+
+	groups := []struct {
+		sign  string
+		color color.Attribute
+		files []string
+	}{
+		{sign: "+", color: color.FgGreen, files: changes.Added},
+		{sign: "~", color: color.FgHiYellow, files: changes.Modified},
+		{sign: "-", color: color.FgHiRed, files: changes.Deleted},
+	}
 
-	config.SymfonyEnv = *env
-	config.ClearCache = *clearCache
-	config.SymfonyDebug = !*noDebug
+	for _, group := range groups {
+		for _, file := range group.files {
+			if limit > 0 && len(lines) == limit {
+				return append(lines, fmt.Sprintf("   ... and %d more", changes.Len()-limit))
+			}
 
-	if *forceClearCache {
-		config.ClearCache = false
-		config.ForceClearCache = true
+			if rel, err := filepath.Rel(projectDir, file); err == nil && filepath.IsAbs(file) {
+				file = rel
+			}
+			lines = append(lines, fmt.Sprintf("   %s %s", color.New(group.color).Sprint(group.sign), file))
+		}
 	}
 
-	if *vendors != "" {
-		vendorList := ParseCommaSeparated(*vendors)
-		if len(vendorList) > 0 {
-			config.VendorWatch = true
-			config.VendorList = vendorList
-		}
+	return lines
+}
+
+// NewWatcher creates the filesystem watcher for the directories holding the watched files.
+// When inotify cannot be used, the reason is printed and the watcher falls back to polling.
+func NewWatcher(config structs.Config) watcher.Watcher {
+	dirsToWatch, err := symfony.GetDirsToWatch(config)
+	if err != nil {
+		PrintError(fmt.Errorf("can't list the directories to watch, falling back to polling: %v", err))
+		return watcher.NewPoller(config.SleepTime)
 	}
 
-	if *exclude != "" {
-		excludeDirs := ParseCommaSeparated(*exclude)
-		config.DirsExclude = append(config.DirsExclude, excludeDirs...)
+	fsWatcher, err := watcher.New(config, dirsToWatch)
+	if err != nil {
+		PrintError(fmt.Errorf("inotify unavailable, falling back to polling: %v", err))
 	}
 
-	if pools.IsChanged() {
-		config.PoolsProvided = true
-		config.Pools = pools.Get()
+	return fsWatcher
+}
 
-		if len(config.Pools) == 0 {
-			config.Pools = []string{"--all"}
-		}
+// WatcherKind returns how the watcher detects changes: inotify or polling.
+func WatcherKind(fsWatcher watcher.Watcher) string {
+	if _, ok := fsWatcher.(*watcher.Poller); ok {
+		return "polling"
 	}
 
-	config.DirSymfonyProject, err = symfony.GetSymfonyProjectDir()
+	return "inotify"
+}
 
+// SyncWatcher registers the directories created since the last scan on the watcher.
+// If the inotify watch limit is reached, the watcher is replaced by a poller.
+func SyncWatcher(config structs.Config, fsWatcher watcher.Watcher) watcher.Watcher {
+	dirsToWatch, err := symfony.GetDirsToWatch(config)
 	if err != nil {
-		PrintError(fmt.Errorf("project directory not found"))
-		PrintError(err)
-		os.Exit(1)
+		return fsWatcher
 	}
 
-	fmt.Println(" > Project directory: " + color.New(color.FgGreen).Sprintf(config.DirSymfonyProject))
-
-	err = symfony.CheckSymfonyConsole(config)
-	if err != nil {
-		PrintError(fmt.Errorf("symfony console not found"))
-		PrintError(err)
-		os.Exit(1)
+	if err = fsWatcher.Watch(dirsToWatch); errors.Is(err, watcher.ErrWatchLimit) {
+		PrintError(fmt.Errorf("%v, falling back to polling", err))
+		_ = fsWatcher.Close()
+		return watcher.NewPoller(config.SleepTime)
 	}
 
-	fmt.Println(" > Symfony console path: " + color.New(color.FgGreen).Sprintf(config.SymfonyConsolePath))
+	return fsWatcher
+}
 
-	out, err := symfony.Version(config)
-	if err != nil {
-		PrintError(fmt.Errorf("error while running the Symfony version command"))
-		PrintError(err)
-		os.Exit(1)
-	}
+func FormatDuration(ms int64) string {
+	const (
+		msInSecond = 1000
+		msInMinute = 60000
+	)
 
-	fmt.Println(" > Symfony env: " + color.New(color.FgGreen).Sprintf(strings.TrimSpace(fmt.Sprintf("%s", out))))
+	minutes := ms / msInMinute
+	ms = ms % msInMinute
+	seconds := ms / msInSecond
+	ms = ms % msInSecond
 
-	start := time.Now()
-	filesToWatch, _ := symfony.GetWatchMap(config)
-	end := time.Now()
-	elapsed := end.Sub(start)
+	var result []string
 
-	if len(filesToWatch) == 0 {
-		PrintError(fmt.Errorf("no file to watch found"))
-		os.Exit(0)
+	if minutes > 0 {
+		result = append(result, fmt.Sprintf("%d minute(s)", minutes))
+	}
+	if seconds > 0 {
+		result = append(result, fmt.Sprintf("%d second(s)", seconds))
+	}
+	if ms > 0 || len(result) == 0 {
+		result = append(result, fmt.Sprintf("%d millisecond(s)", ms))
 	}
 
-	fmt.Println(fmt.Sprintf(" > %s file(s) watched at %s in %s", color.YellowString("%d", len(filesToWatch)), color.YellowString("%s", config.DirSymfonyProject), color.YellowString("%s", FormatDuration(elapsed.Milliseconds()))))
-	fmt.Println(fmt.Sprintf(" > %s to stop watching or run %s %s.", color.GreenString("CTRL+C"), color.GreenString("kill -9"), color.GreenString("%d", os.Getpid())))
+	return strings.Join(result, ", ")
+}
 
-	MainLoop(config, filesToWatch)
+// main is the entry point of the program. It runs the subcommand selected by the command line arguments
+// and exits with its status.
+func main() {
+	os.Exit(Run(os.Args[1:]))
 }
 
 // ParseCommaSeparated splits a comma-separated input string and returns an array of strings.
 // If the input string is empty, it returns an empty array.
 func ParseCommaSeparated(input string) []string {
-	if input == "" {
-		return []string{}
-	}
-
-	return strings.Split(input, ",")
+	return structs.ParseCommaSeparated(input)
 }
 
 // GenerateSeparator returns a string consisting of a specified number of em dashes.
@@ -231,3 +409,39 @@ func PrintError(err error) {
 
 	fmt.Println(fmt.Sprintf("%s %s /!\\", color.New(color.FgHiRed).Sprint("/!\\"), err))
 }
+
+// PrintWarning prints an error that does not stop the command, like PrintError but with a yellow symbol.
+func PrintWarning(err error) {
+	if err == nil {
+		return
+	}
+
+	fmt.Println(fmt.Sprintf("%s %s /!\\", color.New(color.FgHiYellow).Sprint("/!\\"), err))
+}
+
+// PrintCommandError prints the error like PrintError. When the error comes from a failed console command,
+// the last lines of the command output are printed below it.
+func PrintCommandError(err error) {
+	PrintError(err)
+
+	var cmdErr *symfony.CommandError
+	if errors.As(err, &cmdErr) {
+		for _, line := range cmdErr.Tail(outputTailLines) {
+			fmt.Println("   " + color.New(color.FgHiBlack).Sprint(line))
+		}
+	}
+}
+
+// PrintValidationError prints each problem of a *structs.ValidationError on its own line, or the error itself otherwise.
+func PrintValidationError(err error) {
+	var validationErr *structs.ValidationError
+	if !errors.As(err, &validationErr) {
+		PrintError(err)
+		return
+	}
+
+	PrintError(fmt.Errorf("invalid configuration, %d problem(s) found", len(validationErr.Problems)))
+	for _, problem := range validationErr.Problems {
+		PrintError(problem)
+	}
+}
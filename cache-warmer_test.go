@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+	"github.com/lettland/cache-warmer/symfony"
+	"github.com/lettland/cache-warmer/watcher"
 )
 
 func TestParseCommaSeparated(t *testing.T) {
@@ -134,3 +147,408 @@ func TestPrintError(t *testing.T) {
 		})
 	}
 }
+
+func TestPrintWarning(t *testing.T) {
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	PrintWarning(errors.New("test warning"))
+	PrintWarning(nil)
+
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	if got, want := string(out), "/!\\ test warning /!\\\n"; got != want {
+		t.Errorf("PrintWarning() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatChanges(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = old })
+
+	changes := symfony.Changes{
+		Added:    []string{"/app/src/Controller/HomeController.php"},
+		Modified: []string{"/app/config/services.yaml", "/app/src/Kernel.php"},
+		Deleted:  []string{"/app/templates/base.html.twig"},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "No limit",
+			limit: 0,
+			want: []string{
+				"   + src/Controller/HomeController.php",
+				"   ~ config/services.yaml",
+				"   ~ src/Kernel.php",
+				"   - templates/base.html.twig",
+			},
+		},
+		{
+			name:  "Truncated",
+			limit: 2,
+			want: []string{
+				"   + src/Controller/HomeController.php",
+				"   ~ config/services.yaml",
+				"   ... and 2 more",
+			},
+		},
+		{
+			name:  "Limit equal to the number of changes",
+			limit: 4,
+			want: []string{
+				"   + src/Controller/HomeController.php",
+				"   ~ config/services.yaml",
+				"   ~ src/Kernel.php",
+				"   - templates/base.html.twig",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatChanges("/app", changes, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FormatChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newFakeProject(t *testing.T, script string) structs.Config {
+	t.Helper()
+
+	var config structs.Config
+	config.Init()
+	config.DirSymfonyProject = t.TempDir()
+
+	consolePath := filepath.Join(config.DirSymfonyProject, config.SymfonyConsolePath)
+	if err := os.MkdirAll(filepath.Dir(consolePath), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(consolePath, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestMainLoop_TouchedFile(t *testing.T) {
+	config := newFakeProject(t, "echo ok")
+	config.HashContent = true
+	for _, file := range []string{"config/.keep", "src/Kernel.php", "templates/.keep", "translations/.keep", "migrations/.keep"} {
+		path := filepath.Join(config.DirSymfonyProject, file)
+		_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		_ = os.WriteFile(path, []byte("<?php"), 0o644)
+	}
+
+	filesToWatch, err := symfony.GetWatchMap(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Touching the file moves its modification time, but not its content
+	kernel := filepath.Join(config.DirSymfonyProject, "src", "Kernel.php")
+	touched := time.Now().Add(time.Minute)
+	if err = os.Chtimes(kernel, touched, touched); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	before := symfony.FilesHashed()
+	MainLoop(ctx, config, filesToWatch, watcher.NewPoller(5*time.Millisecond), nil)
+
+	// The first scan hashes the touched file again, the following ones reuse its new state
+	if hashed := symfony.FilesHashed() - before; hashed != 1 {
+		t.Errorf("MainLoop() hashed %d file(s), want 1", hashed)
+	}
+}
+
+// fakeWatcher is a watcher whose events are sent by the test.
+type fakeWatcher struct {
+	events chan struct{}
+	once   sync.Once
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{events: make(chan struct{}, 1)}
+}
+
+func (w *fakeWatcher) Events() <-chan struct{} {
+	return w.events
+}
+
+func (w *fakeWatcher) Watch(map[string]bool) error {
+	return nil
+}
+
+func (w *fakeWatcher) Close() error {
+	w.once.Do(func() { close(w.events) })
+	return nil
+}
+
+// newWarmupProject returns a project whose console appends "start" and "end" to the returned log, along
+// with "overlap" when the previous console is still running. The first console sleeps for firstSleep.
+func newWarmupProject(t *testing.T, firstSleep string) (structs.Config, string) {
+	t.Helper()
+
+	log := filepath.Join(t.TempDir(), "warmups.log")
+	config := newFakeProject(t, fmt.Sprintf(`log=%q
+[ -f "$log.pid" ] && kill -0 "$(cat "$log.pid")" 2>/dev/null && echo overlap >> "$log"
+echo $$ > "$log.pid"
+echo start >> "$log"
+[ "$(grep -c start "$log")" -eq 1 ] && sleep %s
+echo end >> "$log"`, log, firstSleep))
+	config.Debounce = 0
+	for _, file := range []string{"config/.keep", "src/Kernel.php", "templates/.keep", "translations/.keep", "migrations/.keep"} {
+		path := filepath.Join(config.DirSymfonyProject, file)
+		_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		_ = os.WriteFile(path, []byte("<?php"), 0o644)
+	}
+
+	return config, log
+}
+
+// changeFile modifies a watched file of the project and notifies the watcher.
+func changeFile(t *testing.T, config structs.Config, fsWatcher *fakeWatcher) {
+	t.Helper()
+
+	file, err := os.OpenFile(filepath.Join(config.DirSymfonyProject, "src", "Kernel.php"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString("\n")
+	_ = file.Close()
+
+	fsWatcher.events <- struct{}{}
+}
+
+// waitLog waits for the log of newWarmupProject to hold the given lines.
+func waitLog(t *testing.T, log string, want []string) {
+	t.Helper()
+
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		data, _ := os.ReadFile(log)
+		if got = strings.Fields(string(data)); reflect.DeepEqual(got, want) {
+			return
+		}
+	}
+	t.Fatalf("warmups log = %v, want %v", got, want)
+}
+
+func TestMainLoop_WarmupPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		firstSleep string
+		changes    int // Changes made during the first warmup
+		wantLog    []string
+	}{
+		{name: "Queue", policy: structs.PolicyQueue, firstSleep: "1", changes: 2, wantLog: []string{"start", "end", "start", "end"}},
+		{name: "Restart", policy: structs.PolicyRestart, firstSleep: "30", changes: 1, wantLog: []string{"start", "start", "end"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, log := newWarmupProject(t, tt.firstSleep)
+			config.WarmupPolicy = tt.policy
+
+			filesToWatch, err := symfony.GetWatchMap(config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			fsWatcher := newFakeWatcher()
+			stopped := make(chan struct{})
+			go func() {
+				MainLoop(ctx, config, filesToWatch, fsWatcher, nil)
+				close(stopped)
+			}()
+			defer func() {
+				cancel()
+				<-stopped
+			}()
+
+			changeFile(t, config, fsWatcher)
+			waitLog(t, log, []string{"start"})
+			for i := 0; i < tt.changes; i++ {
+				changeFile(t, config, fsWatcher)
+				time.Sleep(2 * structs.GracePeriod)
+			}
+
+			waitLog(t, log, tt.wantLog)
+			time.Sleep(200 * time.Millisecond) // No other warmup follows
+			waitLog(t, log, tt.wantLog)
+		})
+	}
+}
+
+func TestMainLoop_WatcherClosed(t *testing.T) {
+	config, log := newWarmupProject(t, "30")
+
+	filesToWatch, err := symfony.GetWatchMap(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsWatcher := newFakeWatcher()
+	stopped := make(chan struct{})
+	go func() {
+		MainLoop(context.Background(), config, filesToWatch, fsWatcher, nil)
+		close(stopped)
+	}()
+
+	changeFile(t, config, fsWatcher)
+	waitLog(t, log, []string{"start"})
+	_ = fsWatcher.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("MainLoop() did not return once the watcher was closed")
+	}
+
+	data, err := os.ReadFile(log + ".pid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if process, err := os.FindProcess(pid); err == nil && process.Signal(syscall.Signal(0)) == nil {
+		_ = process.Kill()
+		t.Error("MainLoop() returned with the warmup still running")
+	}
+}
+
+func TestCollectChanges_TransientFile(t *testing.T) {
+	config := newFakeProject(t, "echo ok")
+	for _, file := range []string{"config/.keep", "src/Kernel.php", "templates/.keep", "translations/.keep", "migrations/.keep", "public/index.php"} {
+		path := filepath.Join(config.DirSymfonyProject, file)
+		_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		_ = os.WriteFile(path, []byte("<?php"), 0o644)
+	}
+
+	for _, debounce := range []time.Duration{0, 20 * time.Millisecond} {
+		t.Run(debounce.String(), func(t *testing.T) {
+			config.Debounce = debounce
+			before, err := symfony.GetWatchMap(config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The file is seen by the first scan, then removed before the changes settle
+			draft := filepath.Join(config.DirSymfonyProject, "src", "draft.php")
+			_ = os.WriteFile(draft, []byte("<?php"), 0o644)
+			updated, err := symfony.GetWatchMap(config, before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = os.Remove(draft)
+
+			fsWatcher := watcher.NewPoller(time.Hour)
+			defer fsWatcher.Close()
+
+			settled, err := CollectChanges(context.Background(), config, fsWatcher, updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changes := symfony.Diff(before, settled); !changes.IsEmpty() {
+				t.Errorf("CollectChanges() changes = %+v, want none", changes)
+			}
+		})
+	}
+}
+
+func TestCollectChanges_Canceled(t *testing.T) {
+	config := newFakeProject(t, "echo ok")
+	config.Debounce = time.Hour
+
+	fsWatcher := watcher.NewPoller(time.Hour)
+	defer fsWatcher.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := CollectChanges(ctx, config, fsWatcher, symfony.Snapshot{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CollectChanges() returned after %v, want it to stop once canceled", elapsed)
+	}
+}
+
+func TestStartWarmup(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		cancel       bool
+		wantErr      bool
+		wantCanceled bool
+	}{
+		{name: "Successful warmup", script: "echo ok", wantErr: false, wantCanceled: false},
+		{name: "Failed warmup", script: "exit 1", wantErr: true, wantCanceled: false},
+		{name: "Canceled warmup", script: "exec sleep 10", cancel: true, wantErr: true, wantCanceled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warmup := StartWarmup(context.Background(), newFakeProject(t, tt.script))
+			if tt.cancel {
+				warmup.Cancel()
+			}
+
+			select {
+			case err := <-warmup.Done:
+				if (err != nil) != tt.wantErr {
+					t.Errorf("StartWarmup() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("StartWarmup() did not complete")
+			}
+
+			if warmup.Canceled() != tt.wantCanceled {
+				t.Errorf("Warmup.Canceled() = %v, want %v", warmup.Canceled(), tt.wantCanceled)
+			}
+		})
+	}
+}
+
+func TestPrintCommandError(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = old })
+
+	err := fmt.Errorf("failed to clear pool: %w", &symfony.CommandError{
+		Args:     []string{"cache:warmup"},
+		ExitCode: 1,
+		Output:   "line 1\nline 2\n",
+	})
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	PrintCommandError(err)
+
+	w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	want := "/!\\ failed to clear pool: symfony command failed: cache:warmup: exit status 1 /!\\\n   line 1\n   line 2\n"
+	if string(out) != want {
+		t.Errorf("PrintCommandError() = %q, want %q", string(out), want)
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+	"github.com/lettland/cache-warmer/symfony"
+)
+
+// Exit statuses of the subcommands, so that scripts can tell why a command failed.
+const (
+	ExitOK              = 0   // The command succeeded
+	ExitFailure         = 1   // The command failed, such as for an invalid configuration
+	ExitUsage           = 2   // The command line is invalid
+	ExitProjectNotFound = 3   // The project directory does not exist
+	ExitConsoleNotFound = 4   // The Symfony console is missing or not executable
+	ExitClearFailed     = 5   // Clearing or removing the cache failed
+	ExitPoolClearFailed = 6   // Clearing a cache pool failed
+	ExitWarmupFailed    = 7   // Warming up the cache failed
+	ExitCanceled        = 130 // The command was interrupted, as shells report for SIGINT
+)
+
+// projectDirNote explains how the project directory is found when no path is given.
+const projectDirNote = "Without path, the project is the first directory holding composer.json and the Symfony console,\nfrom the working directory upwards."
+
+// defaultSubcommand is run when the first argument is not a subcommand name, so that
+// "cache-warmer [flags] <path>" keeps watching the project.
+const defaultSubcommand = "watch"
+
+// Subcommand is a cache-warmer command with its own flag set and help text.
+type Subcommand struct {
+	Name        string
+	Summary     string                        // One line description shown in the list of commands
+	Description string                        // Description shown by the command help
+	Flags       []func(flags *flag.FlagSet)   // Flag groups registered on the command flag set
+	Run         func(flags *flag.FlagSet) int // Runs the command once its flags are parsed and returns the exit status
+}
+
+// Subcommands returns the available subcommands, in the order they are listed in the help.
+func Subcommands() []Subcommand {
+	return []Subcommand{
+		{
+			Name:        "watch",
+			Summary:     "Watch the project and warm up the cache on changes (default)",
+			Description: "Watch the Symfony project at path and warm up the cache each time a watched file changes.",
+			Flags:       []func(flags *flag.FlagSet){addConsoleFlags, addWarmupFlags, addWatchSetFlags, addWatcherFlags},
+			Run:         runWatch,
+		},
+		{
+			Name:        "warm",
+			Summary:     "Clear and warm up the cache once, then exit",
+			Description: warmDescription,
+			Flags:       []func(flags *flag.FlagSet){addConsoleFlags, addWarmupFlags},
+			Run:         runWarm,
+		},
+		{
+			Name:        "list",
+			Summary:     "Print the files watched in the project",
+			Description: "Print the files that the watch command would watch in the Symfony project at path, relative to it.",
+			Flags:       []func(flags *flag.FlagSet){addWatchSetFlags},
+			Run:         runList,
+		},
+		{
+			Name:        "status",
+			Summary:     "Show the state of the watch command running on the project",
+			Description: "Show the state of the watch command running on the Symfony project at path.\nThe exit status is 0 when a watch command is running, 1 otherwise.",
+			Run:         runStatus,
+		},
+		{
+			Name:        "init",
+			Summary:     "Write a config file for the project",
+			Description: fmt.Sprintf("Detect the layout of the Symfony project at path from composer.json, its directories and .gitignore,\nthen write a commented %s with the detected directories, excludes and vendors.", structs.ConfigFile),
+			Flags:       []func(flags *flag.FlagSet){addInitFlags},
+			Run:         runInit,
+		},
+		{
+			Name:        "doctor",
+			Summary:     "Check the project setup",
+			Description: "Check that the Symfony project at path can be watched and warmed up.",
+			Flags:       []func(flags *flag.FlagSet){addConsoleFlags, addWatchSetFlags, addWatcherFlags},
+			Run:         runDoctor,
+		},
+	}
+}
+
+// FindSubcommand returns the subcommand with the given name.
+func FindSubcommand(name string) (Subcommand, bool) {
+	for _, subcommand := range Subcommands() {
+		if subcommand.Name == name {
+			return subcommand, true
+		}
+	}
+
+	return Subcommand{}, false
+}
+
+// Run dispatches the command line arguments, without the program name, to the matching subcommand
+// and returns its exit status. Arguments not starting with a subcommand name, or no argument at all,
+// run the watch command.
+func Run(args []string) int {
+	if len(args) == 0 {
+		args = []string{defaultSubcommand}
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if subcommand, ok := FindSubcommand(args[1]); ok {
+				NewFlagSet(subcommand, os.Stdout).Usage()
+				return ExitOK
+			}
+		}
+		PrintUsage(os.Stdout)
+		return ExitOK
+	}
+
+	subcommand, ok := FindSubcommand(args[0])
+	if ok {
+		args = args[1:]
+	} else {
+		subcommand, _ = FindSubcommand(defaultSubcommand)
+	}
+
+	flags := NewFlagSet(subcommand, os.Stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	return subcommand.Run(flags)
+}
+
+// NewFlagSet returns the flag set of the subcommand, printing its help and errors to out.
+func NewFlagSet(subcommand Subcommand, out io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(subcommand.Name, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	for _, addFlags := range subcommand.Flags {
+		addFlags(flags)
+	}
+
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(out, "Usage: %s %s [flags] [path]\n\n", filepath.Base(os.Args[0]), subcommand.Name)
+		_, _ = fmt.Fprintf(out, "%s\n%s\n", subcommand.Description, projectDirNote)
+		if len(subcommand.Flags) == 0 {
+			return
+		}
+
+		_, _ = fmt.Fprintf(out, "\nFlags:\n")
+		flags.PrintDefaults()
+
+		settings := false
+		flags.VisitAll(func(f *flag.Flag) { settings = settings || flagKeys[f.Name] != "" })
+		if !settings {
+			return
+		}
+		_, _ = fmt.Fprintf(out, "\nSettings are read, each source overriding the previous one, from the defaults, %s, %s,\n", structs.ConfigFile, structs.LocalConfigFile)
+		_, _ = fmt.Fprintf(out, "the %s* environment variables (e.g. %s=test) and finally the flags above.\n", structs.EnvPrefix, structs.EnvName("env"))
+	}
+
+	return flags
+}
+
+// PrintUsage prints the list of subcommands to out.
+func PrintUsage(out io.Writer) {
+	name := filepath.Base(os.Args[0])
+
+	_, _ = fmt.Fprintf(out, "Usage: %s [command] [flags] [path]\n\nCommands:\n", name)
+	for _, subcommand := range Subcommands() {
+		_, _ = fmt.Fprintf(out, "  %-8s %s\n", subcommand.Name, subcommand.Summary)
+	}
+	_, _ = fmt.Fprintf(out, "\n%s\nRun \"%s help <command>\" for the flags of a command.\n", projectDirNote, name)
+}
+
+// addConsoleFlags registers the flags changing how the Symfony console is run.
+func addConsoleFlags(flags *flag.FlagSet) {
+	flags.String("env", "dev", "pass --env=env to the symfony console (default: dev)")
+	flags.Bool("no-debug", false, "pass --no-debug to the symfony console (default: false)")
+	flags.String("executor", structs.Executor, "how the console is run (direct|compose|docker|symfony)")
+	flags.String("container", "", "docker compose service or docker container running the console")
+	flags.String("path-map", "", "host to container project path mapping (host:container)")
+	flags.Bool("verbose", false, "stream the console output while commands run (default: false)")
+	flags.Duration("timeout", structs.CommandTimeout, "maximum duration of each console command, 0 for no limit")
+}
+
+// addWarmupFlags registers the flags selecting the commands run by a warmup.
+func addWarmupFlags(flags *flag.FlagSet) {
+	flags.Bool("cache", false, "clear cache instead of just warmup (default: false)")
+	flags.Bool("force", false, "force clear cache (rm -rf var/cache) (default: false)")
+	flags.Var(structs.NewCustomFlag(), "pools", "comma-separated list of pools to clear")
+}
+
+// addWatchSetFlags registers the flags selecting the watched files.
+func addWatchSetFlags(flags *flag.FlagSet) {
+	flags.String("exclude", "", "comma-separated gitignore-style patterns of paths not to watch, !pattern re-adding a path")
+	flags.String("vendor", "", "comma-separated list of vendors to watch")
+	flags.String("strategy", structs.Strategy, "how the watch set is built: from the watched directories, or from the .meta files of the Symfony cache once warmed up (dirs|meta)")
+	flags.String("watch", "", "comma-separated extra directories and files to watch, a trailing ? marking optional ones (lib,modules?)")
+	flags.String("temp-files", strings.Join(structs.DefaultTempFiles, ","), "comma-separated name patterns of editor temporary files not to watch, replacing the default ones")
+	flags.Bool("gitignore", false, "do not watch the paths ignored by the .gitignore files of the project (default: false)")
+}
+
+// addWatcherFlags registers the flags changing how changes are detected and reported.
+func addWatcherFlags(flags *flag.FlagSet) {
+	flags.Int("changes", structs.ChangesLimit, "maximum number of changed files printed before a warmup, 0 for all")
+	flags.String("policy", structs.WarmupPolicy, "what to do when files change during a warmup: queue a follow-up warmup or restart it (queue|restart)")
+	flags.Duration("debounce", structs.Debounce, "quiet period merging bursts of changes into a single warmup, 0 to disable")
+	flags.Bool("hash", false, "only treat a file as changed when its content differs (default: false)")
+	flags.Bool("poll", false, "poll the filesystem instead of using inotify (default: false)")
+}
+
+// flagKeys maps the command line flags to the config keys they set.
+var flagKeys = map[string]string{
+	"cache":      "clear_cache",
+	"changes":    "changes_limit",
+	"container":  "container",
+	"debounce":   "debounce",
+	"env":        "env",
+	"exclude":    "exclude",
+	"executor":   "executor",
+	"force":      "force_clear_cache",
+	"gitignore":  "gitignore",
+	"hash":       "hash",
+	"no-debug":   "debug",
+	"path-map":   "path_map",
+	"policy":     "policy",
+	"poll":       "poll",
+	"pools":      "pools",
+	"strategy":   "strategy",
+	"temp-files": "temp_files",
+	"timeout":    "timeout",
+	"vendor":     "vendors",
+	"verbose":    "verbose",
+	"watch":      "watch",
+}
+
+// ApplyFlags sets the config keys of the flags explicitly passed on the command line, so that they
+// override the default values and the values read from the config files and the environment.
+func ApplyFlags(config *structs.Config, flags *flag.FlagSet) error {
+	var err error
+
+	flags.Visit(func(f *flag.Flag) {
+		key, ok := flagKeys[f.Name]
+		if !ok || err != nil {
+			return
+		}
+
+		value := f.Value.String()
+		if f.Name == "no-debug" {
+			value = strconv.FormatBool(value != "true")
+		}
+
+		if setErr := config.Set(key, value); setErr != nil {
+			err = fmt.Errorf("invalid --%s flag: %w", f.Name, setErr)
+		}
+	})
+
+	return err
+}
+
+// LoadConfig builds the configuration of a subcommand from the defaults, the config files of the project
+// given as first positional argument, the environment variables and the flags explicitly set, then validates it.
+// The settings of the watch set are only validated when watchSet is set, for the commands building one.
+// The project directory and each source used are reported on out.
+func LoadConfig(flags *flag.FlagSet, out io.Writer, watchSet bool) (structs.Config, error) {
+	var config structs.Config
+	var err error
+	config.Init()
+
+	config.DirSymfonyProject, err = GetProjectDir(flags.Args())
+	if err != nil {
+		return config, fmt.Errorf("%w: %w", structs.ErrProjectNotFound, err)
+	}
+
+	_, _ = fmt.Fprintln(out, " > Project directory: "+color.New(color.FgGreen).Sprintf(config.DirSymfonyProject))
+
+	configFiles, err := config.LoadFiles(config.DirSymfonyProject)
+	if err != nil {
+		return config, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	for _, configFile := range configFiles {
+		_, _ = fmt.Fprintln(out, " > Config file: "+color.New(color.FgGreen).Sprintf(configFile))
+	}
+
+	envVariables, err := config.LoadEnv(os.Environ())
+	if err != nil {
+		return config, fmt.Errorf("invalid environment variable: %w", err)
+	}
+
+	if len(envVariables) > 0 {
+		_, _ = fmt.Fprintln(out, " > Environment variables: "+color.New(color.FgGreen).Sprintf(strings.Join(envVariables, ", ")))
+	}
+
+	if err = ApplyFlags(&config, flags); err != nil {
+		return config, err
+	}
+
+	if !watchSet {
+		return config, config.ValidateConsole()
+	}
+
+	return config, config.Validate()
+}
+
+// PrintVersion prints the version of the program, linked to its release or commit.
+func PrintVersion() {
+	clickableVersion := fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", GenerateVersionLink(version), version)
+	fmt.Println(fmt.Sprintf(" > Version: %s", color.New(color.FgHiYellow).Sprintf(clickableVersion)))
+}
+
+// ConfigExitCode returns the exit status for an error returned by LoadConfig.
+func ConfigExitCode(err error) int {
+	switch {
+	case errors.Is(err, structs.ErrProjectNotFound):
+		return ExitProjectNotFound
+	case errors.Is(err, structs.ErrConsoleNotFound):
+		return ExitConsoleNotFound
+	default:
+		return ExitFailure
+	}
+}
+
+// prepareConsole loads the configuration, checks the Symfony console and prints the executor running it,
+// as the watch and warm commands need, the watch set being validated when watchSet is set. Once a problem is
+// printed, it returns the exit status to use, ExitOK otherwise.
+func prepareConsole(flags *flag.FlagSet, watchSet bool) (structs.Config, int) {
+	fmt.Println()
+	PrintVersion()
+
+	config, err := LoadConfig(flags, os.Stdout, watchSet)
+	if err != nil {
+		PrintValidationError(err)
+		return config, ConfigExitCode(err)
+	}
+
+	if err = symfony.CheckSymfonyConsole(config); err != nil {
+		PrintError(fmt.Errorf("symfony console not found"))
+		PrintError(err)
+		return config, ExitConsoleNotFound
+	}
+
+	fmt.Println(" > Symfony console path: " + color.New(color.FgGreen).Sprintf(config.SymfonyConsolePath))
+
+	consoleExecutor, err := symfony.NewExecutor(config)
+	if err != nil {
+		PrintError(fmt.Errorf("invalid executor"))
+		PrintError(err)
+		return config, ExitFailure
+	}
+
+	fmt.Println(" > Symfony console executor: " + color.New(color.FgGreen).Sprintf(consoleExecutor.String()))
+
+	return config, ExitOK
+}
+
+// runWatch prints some information about the project and enters the main loop to monitor and react to
+// file changes, until it receives an interrupt or termination signal.
+func runWatch(flags *flag.FlagSet) int {
+	config, code := prepareConsole(flags, true)
+	if code != ExitOK {
+		return code
+	}
+	for _, warning := range config.Warnings() {
+		PrintWarning(warning)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	out, err := symfony.Version(ctx, config)
+	if err != nil {
+		PrintError(fmt.Errorf("error while running the Symfony version command"))
+		PrintCommandError(err)
+		return ExitFailure
+	}
+
+	fmt.Println(" > Symfony env: " + color.New(color.FgGreen).Sprintf(strings.TrimSpace(fmt.Sprintf("%s", out))))
+
+	fsWatcher := NewWatcher(config)
+
+	start := time.Now()
+	filesToWatch, err := symfony.GetWatchMap(config, nil)
+	end := time.Now()
+	elapsed := end.Sub(start)
+
+	if filesToWatch == nil {
+		PrintError(err)
+		return ExitFailure
+	}
+	// Unreadable files are skipped, keeping the watch going
+	PrintWarning(err)
+
+	if len(filesToWatch) == 0 {
+		PrintError(fmt.Errorf("no file to watch found"))
+		return ExitOK
+	}
+
+	fmt.Println(fmt.Sprintf(" > %s file(s) watched at %s in %s", color.YellowString("%d", len(filesToWatch)), color.YellowString("%s", config.DirSymfonyProject), color.YellowString("%s", FormatDuration(elapsed.Milliseconds()))))
+	fmt.Println(fmt.Sprintf(" > %s to stop watching or run %s %s.", color.GreenString("CTRL+C"), color.GreenString("kill -9"), color.GreenString("%d", os.Getpid())))
+
+	status, err := NewStatusFile(Status{
+		PID:          os.Getpid(),
+		Version:      version,
+		ProjectDir:   config.DirSymfonyProject,
+		Started:      time.Now(),
+		Watcher:      WatcherKind(fsWatcher),
+		FilesWatched: len(filesToWatch),
+		State:        StateIdle,
+	})
+	if err != nil {
+		PrintError(fmt.Errorf("can't write the status file, the status command won't see this watcher: %v", err))
+	}
+	defer status.Remove()
+
+	MainLoop(ctx, config, filesToWatch, fsWatcher, status)
+
+	fmt.Println()
+	fmt.Println(fmt.Sprintf(" > %s", color.New(color.FgHiYellow).Sprintf("Stopped watching")))
+
+	return ExitOK
+}
+
+// runList prints the watched files relative to the project directory, one per line, so that the
+// output can be piped to other tools. The warnings are printed to stderr, out of the list.
+func runList(flags *flag.FlagSet) int {
+	config, err := LoadConfig(flags, io.Discard, true)
+	if err != nil {
+		PrintValidationError(err)
+		return ConfigExitCode(err)
+	}
+	for _, warning := range config.Warnings() {
+		_, _ = fmt.Fprintln(os.Stderr, "warning: "+warning.Error())
+	}
+
+	files, err := symfony.GetFilesToWatch(config)
+	if err != nil {
+		PrintError(err)
+		return ExitFailure
+	}
+
+	for _, file := range ListFiles(config.DirSymfonyProject, files) {
+		fmt.Println(file)
+	}
+
+	return ExitOK
+}
+
+// ListFiles returns the files relative to the project directory, sorted.
+func ListFiles(projectDir string, files []string) []string {
+	list := make([]string, 0, len(files))
+	for _, file := range files {
+		if rel, err := filepath.Rel(projectDir, file); err == nil && filepath.IsAbs(file) {
+			file = rel
+		}
+		list = append(list, file)
+	}
+	slices.Sort(list)
+
+	return list
+}
+
+// runStatus prints the status of the watch command running on the project.
+func runStatus(flags *flag.FlagSet) int {
+	projectDir, err := GetProjectDir(flags.Args())
+	if err != nil {
+		PrintError(fmt.Errorf("%w: %w", structs.ErrProjectNotFound, err))
+		return ExitProjectNotFound
+	}
+
+	status, err := ReadStatus(projectDir)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println(fmt.Sprintf(" > %s at %s", color.New(color.FgHiYellow).Sprintf("Not watching"), color.YellowString("%s", projectDir)))
+		return ExitFailure
+	}
+	if err != nil {
+		PrintError(err)
+		return ExitFailure
+	}
+
+	for _, line := range FormatStatus(status, time.Now()) {
+		fmt.Println(line)
+	}
+
+	return ExitOK
+}
+
+// FormatStatus returns the lines describing the status of a watch command at the given time.
+func FormatStatus(status Status, now time.Time) []string {
+	uptime := now.Sub(status.Started).Truncate(time.Second)
+	lines := []string{
+		fmt.Sprintf(" > %s at %s for %s (pid %d, version %s)", color.GreenString("Watching"), color.YellowString("%s", status.ProjectDir), FormatDuration(uptime.Milliseconds()), status.PID, status.Version),
+		fmt.Sprintf(" > %s file(s) watched with %s", color.YellowString("%d", status.FilesWatched), status.Watcher),
+	}
+
+	if status.State == StateWarming && status.LastWarmup != nil {
+		elapsed := now.Sub(status.LastWarmup.Started).Truncate(time.Millisecond)
+		lines = append(lines, fmt.Sprintf(" > %s for %s", color.New(color.FgHiYellow).Sprintf("Warming up"), FormatDuration(elapsed.Milliseconds())))
+	} else {
+		lines = append(lines, fmt.Sprintf(" > %s", color.GreenString("Idle")))
+	}
+
+	if warmup := status.LastWarmup; warmup != nil && warmup.Result != "" {
+		line := fmt.Sprintf(" > Last warmup %s at %s in %s (%d warmup(s) since start)", warmup.Result, warmup.Started.Format("15:04:05"), FormatDuration(warmup.Duration.Milliseconds()), status.Warmups)
+		lines = append(lines, line)
+		if warmup.Error != "" {
+			lines = append(lines, "   "+color.New(color.FgHiBlack).Sprint(warmup.Error))
+		}
+	}
+
+	return lines
+}
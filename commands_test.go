@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+func TestRun(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	devNull, _ := os.Open(os.DevNull)
+	defer devNull.Close()
+	os.Stdout, os.Stderr = devNull, devNull
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "Help", args: []string{"help"}, want: ExitOK},
+		{name: "Command help", args: []string{"help", "warm"}, want: ExitOK},
+		{name: "Command help flag", args: []string{"list", "-h"}, want: ExitOK},
+		{name: "Unknown flag", args: []string{"status", "--env=test"}, want: ExitUsage},
+		{name: "Watch flags by default", args: []string{"--bogus", "."}, want: ExitUsage},
+		{name: "Missing project", args: []string{"status", "missingDir"}, want: ExitProjectNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Run(tt.args); got != tt.want {
+				t.Errorf("Run(%v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFlagSet(t *testing.T) {
+	tests := []struct {
+		command string
+		has     []string
+		hasNot  []string
+	}{
+		{command: "watch", has: []string{"env", "pools", "exclude", "debounce"}},
+		{command: "warm", has: []string{"env", "pools"}, hasNot: []string{"exclude", "debounce"}},
+		{command: "list", has: []string{"exclude", "vendor"}, hasNot: []string{"env", "pools"}},
+		{command: "status", hasNot: []string{"env"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			subcommand, ok := FindSubcommand(tt.command)
+			if !ok {
+				t.Fatalf("FindSubcommand(%q) = false, want true", tt.command)
+			}
+
+			flags := NewFlagSet(subcommand, io.Discard)
+			for _, name := range tt.has {
+				if flags.Lookup(name) == nil {
+					t.Errorf("NewFlagSet(%s) has no --%s flag", tt.command, name)
+				}
+			}
+			for _, name := range tt.hasNot {
+				if flags.Lookup(name) != nil {
+					t.Errorf("NewFlagSet(%s) has a --%s flag", tt.command, name)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	project := t.TempDir()
+	// An API-only project without templates, translations nor migrations
+	for _, dir := range []string{"bin", "config", "public", "src"} {
+		if err := os.MkdirAll(filepath.Join(project, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.WriteFile(filepath.Join(project, "bin", "console"), []byte("#!/bin/sh\n"), 0o755)
+	_ = os.WriteFile(filepath.Join(project, "public", "index.php"), []byte("<?php\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(project, structs.ConfigFile), []byte("env: prod\ntimeout: 5s\n"), 0o644)
+
+	subcommand, _ := FindSubcommand("warm")
+	flags := NewFlagSet(subcommand, io.Discard)
+	if err := flags.Parse([]string{"--timeout=1m", project}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(flags, io.Discard, false)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.DirSymfonyProject != project || config.SymfonyEnv != "prod" || config.CommandTimeout != time.Minute {
+		t.Errorf("LoadConfig() = %+v, want the project, env from the file and timeout from the flag", config)
+	}
+	if _, err = LoadConfig(flags, io.Discard, true); err == nil {
+		t.Errorf("LoadConfig() with the watch set error = nil, want the missing directories")
+	}
+
+	if err = flags.Parse([]string{"missingDir"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadConfig(flags, io.Discard, false); err == nil {
+		t.Errorf("LoadConfig() error = nil, want project directory not found")
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	got := ListFiles("/app", []string{"/app/src/Kernel.php", "/app/.env", "/app/config/services.yaml"})
+	want := []string{".env", "config/services.yaml", "src/Kernel.php"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	var config structs.Config
+	config.Init()
+	config.SymfonyEnv = "test" // As read from a config file
+	config.DirSymfonySrc = "lib"
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("env", "dev", "")
+	flags.Bool("no-debug", false, "")
+	flags.String("vendor", "", "")
+	flags.Duration("timeout", 0, "")
+	flags.Var(structs.NewCustomFlag(), "pools", "")
+
+	if err := flags.Parse([]string{"--no-debug", "--vendor=acme/foo", "--timeout=1m", "--pools="}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyFlags(&config, flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.SymfonyEnv != "test" || config.DirSymfonySrc != "lib" {
+		t.Errorf("ApplyFlags() overrode values without flags: %+v", config)
+	}
+	if config.SymfonyDebug || !config.VendorWatch || config.CommandTimeout != time.Minute {
+		t.Errorf("ApplyFlags() did not apply the flags: %+v", config)
+	}
+	if !config.PoolsProvided || !reflect.DeepEqual(config.Pools, []string{"--all"}) {
+		t.Errorf("ApplyFlags() pools = %v, want --all", config.Pools)
+	}
+}
+
+func TestApplyFlags_WatchSet(t *testing.T) {
+	var config structs.Config
+	config.Init()
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	addWatchSetFlags(flags)
+
+	if err := flags.Parse([]string{"--watch=lib,modules?", "--strategy=meta", "--gitignore"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyFlags(&config, flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"lib", "modules?"}; !reflect.DeepEqual(config.Watch, want) {
+		t.Errorf("ApplyFlags() watch = %v, want %v", config.Watch, want)
+	}
+	if config.Strategy != structs.StrategyMeta || !config.Gitignore {
+		t.Errorf("ApplyFlags() did not apply the flags: %+v", config)
+	}
+}
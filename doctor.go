@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+	"github.com/lettland/cache-warmer/symfony"
+	"github.com/lettland/cache-warmer/watcher"
+)
+
+// Levels of the doctor check results, from the best to the worst.
+const (
+	LevelPass = "pass" // Nothing to do
+	LevelSkip = "skip" // Not checked, as a previous check failed or the check does not apply
+	LevelWarn = "warn" // The watcher works, but may misbehave
+	LevelFail = "fail" // The watcher or the warmup cannot work
+)
+
+const (
+	doctorCommandTimeout = 30 * time.Second // Timeout of the version command when none is configured
+	hugeDirFiles         = 5000             // Number of watched files above which a directory is reported
+	watchLimitWarning    = 0.8              // Share of the inotify watch limit above which a warning is reported
+)
+
+// CheckResult is the outcome of a doctor check.
+type CheckResult struct {
+	Name    string // What was checked
+	Level   string // LevelPass, LevelSkip, LevelWarn or LevelFail
+	Message string // What was found
+	Fix     string // How to fix a warning or a failure
+}
+
+// Check is a diagnostic run by the doctor command. It may return several results, such as one per problem.
+type Check struct {
+	Name     string
+	Requires string // Name of a check that must pass first, if any
+	Run      func(ctx context.Context, config structs.Config) []CheckResult
+}
+
+// DoctorChecks returns the checks run by the doctor command, in order.
+func DoctorChecks() []Check {
+	return []Check{
+		{Name: "console", Run: checkConsole},
+		{Name: "runtime", Run: checkRuntime},
+		{Name: "version", Requires: "console", Run: checkVersion},
+		{Name: "app env", Run: checkAppEnv},
+		{Name: "cache dir", Run: checkCacheDir},
+		{Name: "watch set", Run: checkWatchSet},
+		{Name: "inotify", Run: checkInotify},
+	}
+}
+
+// RunDoctor runs the checks in order. A check whose required check did not pass is skipped.
+func RunDoctor(ctx context.Context, config structs.Config, checks []Check) []CheckResult {
+	var results []CheckResult
+	failed := map[string]bool{}
+
+	for _, check := range checks {
+		if check.Requires != "" && failed[check.Requires] {
+			results = append(results, CheckResult{Name: check.Name, Level: LevelSkip, Message: fmt.Sprintf("skipped as the %s check failed", check.Requires)})
+			failed[check.Name] = true
+			continue
+		}
+
+		for _, result := range check.Run(ctx, config) {
+			if result.Name == "" {
+				result.Name = check.Name
+			}
+			failed[check.Name] = failed[check.Name] || result.Level == LevelFail
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// configResults returns one failure per problem of an error returned by LoadConfig, leaving out the
+// console problems reported by the console check.
+func configResults(err error) []CheckResult {
+	if err == nil {
+		return []CheckResult{{Name: "config", Level: LevelPass, Message: "configuration is valid"}}
+	}
+
+	problems := []error{err}
+	var validationErr *structs.ValidationError
+	if errors.As(err, &validationErr) {
+		problems = validationErr.Problems
+	}
+
+	var results []CheckResult
+	for _, problem := range problems {
+		if errors.Is(problem, structs.ErrConsoleNotFound) {
+			continue
+		}
+		results = append(results, CheckResult{
+			Name:    "config",
+			Level:   LevelFail,
+			Message: problem.Error(),
+			Fix:     fmt.Sprintf("fix the value in %s, the %s* environment variables or the flags", structs.ConfigFile, structs.EnvPrefix),
+		})
+	}
+
+	return results
+}
+
+// checkConsole checks that the Symfony console exists and, when run on the host, is executable.
+func checkConsole(_ context.Context, config structs.Config) []CheckResult {
+	if err := symfony.CheckSymfonyConsole(config); err != nil {
+		return fail(err.Error(), fmt.Sprintf("set console_path in %s to the console of the project", structs.ConfigFile))
+	}
+
+	consolePath := filepath.Join(config.DirSymfonyProject, config.SymfonyConsolePath)
+	info, err := os.Stat(consolePath)
+	if err != nil {
+		return fail(err.Error(), "")
+	}
+	if config.Executor == structs.ExecutorDirect && runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		return fail(fmt.Sprintf("%s is not executable", config.SymfonyConsolePath), fmt.Sprintf("run chmod +x %s", config.SymfonyConsolePath))
+	}
+
+	return pass(fmt.Sprintf("%s found", config.SymfonyConsolePath))
+}
+
+// runtimeBinaries lists the program each executor needs on the PATH.
+var runtimeBinaries = map[string]string{
+	structs.ExecutorDirect:  "php",
+	structs.ExecutorCompose: "docker",
+	structs.ExecutorDocker:  "docker",
+	structs.ExecutorSymfony: "symfony",
+}
+
+// checkRuntime checks that the program running the console, such as PHP, is on the PATH.
+func checkRuntime(_ context.Context, config structs.Config) []CheckResult {
+	binary, ok := runtimeBinaries[config.Executor]
+	if !ok {
+		return skip(fmt.Sprintf("unknown executor %s", config.Executor))
+	}
+
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		fix := fmt.Sprintf("install %s or add it to the PATH", binary)
+		if config.Executor == structs.ExecutorDirect {
+			fix += ", or run the console in a container with --executor=compose"
+		}
+		return fail(fmt.Sprintf("%s not found on the PATH", binary), fix)
+	}
+
+	return pass(fmt.Sprintf("%s found at %s", binary, path))
+}
+
+// checkVersion checks that the console runs, using the version command.
+func checkVersion(ctx context.Context, config structs.Config) []CheckResult {
+	if config.CommandTimeout <= 0 {
+		config.CommandTimeout = doctorCommandTimeout
+	}
+
+	out, err := symfony.Version(ctx, config)
+	if err != nil {
+		message := err.Error()
+		var cmdErr *symfony.CommandError
+		if errors.As(err, &cmdErr) {
+			if tail := cmdErr.Tail(1); len(tail) > 0 {
+				message += ": " + strings.TrimSpace(tail[0])
+			}
+		}
+		return fail(message, fmt.Sprintf("run %s --version --env=%s in the project to see the whole error", config.SymfonyConsolePath, config.SymfonyEnv))
+	}
+
+	return pass(strings.TrimSpace(out))
+}
+
+// checkAppEnv checks that the environment warmed up by the console is the one the application runs in.
+func checkAppEnv(_ context.Context, config structs.Config) []CheckResult {
+	appEnv, source := DotenvAppEnv(config.DirSymfonyProject)
+	if value := os.Getenv("APP_ENV"); value != "" && config.Executor == structs.ExecutorDirect {
+		appEnv, source = value, "the environment"
+	}
+
+	switch {
+	case appEnv == "":
+		return pass("APP_ENV not set in the .env files")
+	case appEnv != config.SymfonyEnv:
+		return warn(
+			fmt.Sprintf("APP_ENV is %s in %s, but the cache is warmed up with --env=%s", appEnv, source, config.SymfonyEnv),
+			fmt.Sprintf("pass --env=%s or set env: %s in %s", appEnv, appEnv, structs.ConfigFile),
+		)
+	default:
+		return pass(fmt.Sprintf("APP_ENV is %s in %s", appEnv, source))
+	}
+}
+
+// DotenvAppEnv returns the APP_ENV value of the .env files of the project and the file setting it,
+// or empty strings if none sets it. As with Symfony, .env.local overrides .env.
+func DotenvAppEnv(projectDir string) (string, string) {
+	var appEnv, source string
+
+	for _, name := range []string{".env", ".env.local"} {
+		if value, ok := readDotenv(filepath.Join(projectDir, name), "APP_ENV"); ok {
+			appEnv, source = value, name
+		}
+	}
+
+	return appEnv, source
+}
+
+// readDotenv returns the value of the key in a dotenv file, the last definition winning.
+func readDotenv(path, key string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	var value string
+	found := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "export ")
+
+		name, rest, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+
+		rest = strings.TrimSpace(rest)
+		if unquoted, ok := unquote(rest); ok {
+			value = unquoted
+		} else {
+			value, _, _ = strings.Cut(rest, " #")
+			value = strings.TrimSpace(value)
+		}
+		found = true
+	}
+
+	return value, found
+}
+
+// unquote returns the value between matching single or double quotes.
+func unquote(value string) (string, bool) {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1], true
+		}
+	}
+
+	return "", false
+}
+
+// checkCacheDir checks that the cache directory, or the directory it will be created in, is writable.
+func checkCacheDir(_ context.Context, config structs.Config) []CheckResult {
+	cacheDir := filepath.Join(config.DirSymfonyProject, "var", "cache")
+
+	dir := cacheDir
+	for dir != config.DirSymfonyProject {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	rel, _ := filepath.Rel(config.DirSymfonyProject, dir)
+	file, err := os.CreateTemp(dir, ".cache-warmer-")
+	if err != nil {
+		level := LevelFail
+		if config.Executor != structs.ExecutorDirect {
+			level = LevelWarn // The console runs as another user, who may be allowed to write
+		}
+		return []CheckResult{{
+			Level:   level,
+			Message: fmt.Sprintf("%s is not writable: %v", rel, errors.Unwrap(err)),
+			Fix:     fmt.Sprintf("give the user running the console write access to %s, e.g. chmod -R u+w %s", rel, rel),
+		}}
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+
+	return pass(fmt.Sprintf("%s is writable", rel))
+}
+
+// checkWatchSet checks that files are watched and reports the directories holding too many of them, along
+// with the exclude patterns matching nothing.
+func checkWatchSet(_ context.Context, config structs.Config) []CheckResult {
+	files, err := symfony.GetFilesToWatch(config)
+	if err != nil {
+		return fail(err.Error(), "check the watched directories with the list command")
+	}
+	if len(files) == 0 {
+		return warn("no file to watch found", "check the dir_* settings and the excluded directories")
+	}
+
+	results := pass(fmt.Sprintf("%d file(s) watched", len(files)))
+	for _, dir := range HugeDirs(config.DirSymfonyProject, files, hugeDirFiles) {
+		results = append(results, CheckResult{
+			Level:   LevelWarn,
+			Message: fmt.Sprintf("%s holds %d watched files, which slows down each scan", dir.Path, dir.Files),
+			Fix:     fmt.Sprintf("exclude it with --exclude=%s unless the cache depends on it", dir.Path),
+		})
+	}
+	for _, warning := range config.Warnings() {
+		results = append(results, CheckResult{
+			Level:   LevelWarn,
+			Message: warning.Error(),
+			Fix:     "fix the pattern, or ignore this warning if the path is created later",
+		})
+	}
+
+	return results
+}
+
+// DirCount is a directory along with the number of watched files it holds, recursively.
+type DirCount struct {
+	Path  string // Relative to the project directory
+	Files int
+}
+
+// HugeDirs returns the directories holding more than limit of the files, recursively. Only the deepest
+// such directories are returned, so that a single generated directory is reported rather than all of its
+// parents. The result is sorted by decreasing number of files.
+func HugeDirs(projectDir string, files []string, limit int) []DirCount {
+	counts := map[string]int{}
+	for _, file := range files {
+		rel, err := filepath.Rel(projectDir, file)
+		if err != nil {
+			continue
+		}
+		for dir := filepath.Dir(rel); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			counts[dir]++
+		}
+	}
+
+	var huge []DirCount
+	for dir, count := range counts {
+		if count <= limit {
+			continue
+		}
+
+		deepest := true
+		for other, otherCount := range counts {
+			if otherCount > limit && strings.HasPrefix(other, dir+string(filepath.Separator)) {
+				deepest = false
+				break
+			}
+		}
+		if deepest {
+			huge = append(huge, DirCount{Path: dir, Files: count})
+		}
+	}
+
+	slices.SortFunc(huge, func(a, b DirCount) int {
+		if a.Files != b.Files {
+			return b.Files - a.Files
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return huge
+}
+
+// checkInotify checks that the directories to watch fit in the inotify watch limit.
+func checkInotify(_ context.Context, config structs.Config) []CheckResult {
+	if config.Poll {
+		return skip("polling is enabled")
+	}
+
+	limit, err := watcher.MaxUserWatches()
+	if err != nil {
+		return skip(fmt.Sprintf("%v, polling is used", err))
+	}
+
+	dirs, err := symfony.GetDirsToWatch(config)
+	if err != nil {
+		return fail(err.Error(), "check the watched directories with the list command")
+	}
+
+	fix := "raise the limit with sudo sysctl fs.inotify.max_user_watches=524288, exclude directories, or use --poll"
+	switch {
+	case len(dirs) > limit:
+		return fail(fmt.Sprintf("%d directories to watch, above the limit of %d inotify watches", len(dirs), limit), fix)
+	case float64(len(dirs)) > float64(limit)*watchLimitWarning:
+		return warn(fmt.Sprintf("%d directories to watch, close to the limit of %d inotify watches shared with other programs", len(dirs), limit), fix)
+	default:
+		return pass(fmt.Sprintf("%d directories to watch, the limit is %d inotify watches", len(dirs), limit))
+	}
+}
+
+func pass(message string) []CheckResult {
+	return []CheckResult{{Level: LevelPass, Message: message}}
+}
+
+func skip(message string) []CheckResult {
+	return []CheckResult{{Level: LevelSkip, Message: message}}
+}
+
+func warn(message, fix string) []CheckResult {
+	return []CheckResult{{Level: LevelWarn, Message: message, Fix: fix}}
+}
+
+func fail(message, fix string) []CheckResult {
+	return []CheckResult{{Level: LevelFail, Message: message, Fix: fix}}
+}
+
+// levelStyles holds the symbol and color of each level in the report.
+var levelStyles = map[string]struct {
+	symbol string
+	color  color.Attribute
+}{
+	LevelPass: {symbol: "✔", color: color.FgGreen},
+	LevelSkip: {symbol: "-", color: color.FgHiBlack},
+	LevelWarn: {symbol: "!", color: color.FgHiYellow},
+	LevelFail: {symbol: "✘", color: color.FgHiRed},
+}
+
+// FormatReport returns one line per result, followed by its fix suggestion if any, and a last summary line.
+func FormatReport(results []CheckResult) []string {
+	var lines []string
+	counts := map[string]int{}
+
+	for _, result := range results {
+		style := levelStyles[result.Level]
+		counts[result.Level]++
+
+		lines = append(lines, fmt.Sprintf("   %s %-9s │ %s", color.New(style.color).Sprintf("%s %s", style.symbol, result.Level), result.Name, result.Message))
+		if result.Fix != "" {
+			lines = append(lines, fmt.Sprintf("   %-16s │ %s", "", color.New(color.FgHiBlack).Sprintf("→ %s", result.Fix)))
+		}
+	}
+
+	return append(lines, fmt.Sprintf(" > %d passed, %d warning(s), %d failure(s), %d skipped", counts[LevelPass], counts[LevelWarn], counts[LevelFail], counts[LevelSkip]))
+}
+
+// runDoctor runs the doctor checks on the project and prints the report. It returns ExitFailure if a
+// check failed; warnings alone do not change the exit status.
+func runDoctor(flags *flag.FlagSet) int {
+	fmt.Println()
+	PrintVersion()
+
+	config, err := LoadConfig(flags, os.Stdout, true)
+	if errors.Is(err, structs.ErrProjectNotFound) {
+		PrintError(err)
+		return ExitProjectNotFound
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := append(configResults(err), RunDoctor(ctx, config, DoctorChecks())...)
+
+	fmt.Println(" > Doctor report:")
+	for _, line := range FormatReport(results) {
+		fmt.Println(line)
+	}
+
+	for _, result := range results {
+		if result.Level == LevelFail {
+			return ExitFailure
+		}
+	}
+
+	return ExitOK
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+func TestRunDoctor(t *testing.T) {
+	checks := []Check{
+		{Name: "first", Run: func(context.Context, structs.Config) []CheckResult { return fail("broken", "fix it") }},
+		{Name: "second", Requires: "first", Run: func(context.Context, structs.Config) []CheckResult { return pass("ok") }},
+		{Name: "third", Run: func(context.Context, structs.Config) []CheckResult {
+			return append(pass("ok"), warn("slow", "speed it up")...)
+		}},
+	}
+
+	var got []string
+	for _, result := range RunDoctor(context.Background(), structs.Config{}, checks) {
+		got = append(got, result.Name+" "+result.Level)
+	}
+
+	want := []string{"first fail", "second skip", "third pass", "third warn"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RunDoctor() = %v, want %v", got, want)
+	}
+}
+
+func TestDotenvAppEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantEnv    string
+		wantSource string
+	}{
+		{name: "No file", files: map[string]string{}},
+		{name: "Not set", files: map[string]string{".env": "APP_SECRET=abc\n"}},
+		{name: ".env", files: map[string]string{".env": "# comment\nAPP_ENV=dev\n"}, wantEnv: "dev", wantSource: ".env"},
+		{name: "Quoted", files: map[string]string{".env": "export APP_ENV=\"prod\" # live\n"}, wantEnv: "prod", wantSource: ".env"},
+		{name: "Trailing comment", files: map[string]string{".env": "APP_ENV=prod # live\n"}, wantEnv: "prod", wantSource: ".env"},
+		{name: ".env.local overrides", files: map[string]string{".env": "APP_ENV=dev\n", ".env.local": "APP_ENV='prod'\n"}, wantEnv: "prod", wantSource: ".env.local"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			gotEnv, gotSource := DotenvAppEnv(projectDir)
+			if gotEnv != tt.wantEnv || gotSource != tt.wantSource {
+				t.Errorf("DotenvAppEnv() = %q, %q, want %q, %q", gotEnv, gotSource, tt.wantEnv, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestCheckAppEnv(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+
+	config := newFakeProject(t, "")
+	_ = os.WriteFile(filepath.Join(config.DirSymfonyProject, ".env"), []byte("APP_ENV=prod\n"), 0o644)
+
+	results := checkAppEnv(context.Background(), config)
+	if len(results) != 1 || results[0].Level != LevelWarn || !strings.Contains(results[0].Fix, "--env=prod") {
+		t.Errorf("checkAppEnv() = %+v, want a warning suggesting --env=prod", results)
+	}
+
+	config.SymfonyEnv = "prod"
+	if results = checkAppEnv(context.Background(), config); results[0].Level != LevelPass {
+		t.Errorf("checkAppEnv() = %+v, want a pass", results)
+	}
+}
+
+func TestCheckCacheDir(t *testing.T) {
+	config := newFakeProject(t, "")
+
+	if results := checkCacheDir(context.Background(), config); results[0].Level != LevelPass {
+		t.Errorf("checkCacheDir() = %+v, want a pass for a missing var directory in a writable project", results)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	varDir := filepath.Join(config.DirSymfonyProject, "var")
+	if err := os.Mkdir(varDir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(varDir, 0o755)
+
+	if results := checkCacheDir(context.Background(), config); results[0].Level != LevelFail {
+		t.Errorf("checkCacheDir() = %+v, want a failure for a read-only var directory", results)
+	}
+}
+
+func TestCheckConsole(t *testing.T) {
+	config := newFakeProject(t, "")
+
+	if results := checkConsole(context.Background(), config); results[0].Level != LevelPass {
+		t.Errorf("checkConsole() = %+v, want a pass", results)
+	}
+
+	_ = os.Chmod(filepath.Join(config.DirSymfonyProject, config.SymfonyConsolePath), 0o644)
+	if results := checkConsole(context.Background(), config); results[0].Level != LevelFail || !strings.Contains(results[0].Fix, "chmod +x") {
+		t.Errorf("checkConsole() = %+v, want a failure suggesting chmod +x", results)
+	}
+}
+
+func TestHugeDirs(t *testing.T) {
+	var files []string
+	for i := 0; i < 6; i++ {
+		files = append(files, fmt.Sprintf("/app/src/Entity/File%d.php", i))
+		files = append(files, fmt.Sprintf("/app/src/Generated/Proxy/File%d.php", i))
+		files = append(files, fmt.Sprintf("/app/src/Generated/Proxy/Deep/File%d.php", i))
+	}
+	files = append(files, "/app/config/services.yaml")
+
+	got := HugeDirs("/app", files, 10)
+	want := []DirCount{{Path: filepath.Join("src", "Generated", "Proxy"), Files: 12}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HugeDirs() = %v, want %v", got, want)
+	}
+
+	got = HugeDirs("/app", files, 4)
+	want = []DirCount{
+		{Path: filepath.Join("src", "Entity"), Files: 6},
+		{Path: filepath.Join("src", "Generated", "Proxy", "Deep"), Files: 6},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HugeDirs() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigResults(t *testing.T) {
+	err := &structs.ValidationError{Problems: []error{
+		fmt.Errorf("dir_src: directory lib not found"),
+		fmt.Errorf("console_path: %w", structs.ErrConsoleNotFound),
+	}}
+
+	results := configResults(err)
+	if len(results) != 1 || results[0].Level != LevelFail || results[0].Message != "dir_src: directory lib not found" {
+		t.Errorf("configResults() = %+v, want a single failure without the console problem", results)
+	}
+
+	if results = configResults(nil); len(results) != 1 || results[0].Level != LevelPass {
+		t.Errorf("configResults(nil) = %+v, want a pass", results)
+	}
+}
+
+func TestFormatReport(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = old }()
+
+	got := FormatReport([]CheckResult{
+		{Name: "console", Level: LevelPass, Message: "bin/console found"},
+		{Name: "app env", Level: LevelWarn, Message: "APP_ENV is prod", Fix: "pass --env=prod"},
+	})
+	want := []string{
+		"   ✔ pass console   │ bin/console found",
+		"   ! warn app env   │ APP_ENV is prod",
+		"                    │ → pass --env=prod",
+		" > 1 passed, 1 warning(s), 0 failure(s), 0 skipped",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FormatReport() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,301 @@
+// Package ignore matches paths relative to the project directory against gitignore-style patterns.
+//
+// A pattern without slash, such as node_modules or *.log, matches a path component at any depth. A pattern
+// holding a slash, such as src/**/Fixtures or /var, is anchored to the project directory. In both cases "*"
+// and "?" match within a component, "**" matches any number of components, and a trailing slash restricts
+// the pattern to directories. A pattern starting with "!" re-adds the paths excluded by the previous ones.
+//
+// The patterns of .gitignore files are read with their own semantics: they are relative to the directory of
+// the file, and a path inside an ignored directory stays ignored whatever the patterns following it.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Pattern is a parsed gitignore-style pattern.
+type Pattern struct {
+	source   string
+	negate   bool     // Whether the pattern re-adds the paths it matches
+	dirOnly  bool     // Whether the pattern only matches directories
+	anchored bool     // Whether the pattern is matched from the project directory rather than at any depth
+	parts    []string // Components of the pattern, "**" matching any number of path components
+	base     []string // Components of the directory the pattern is relative to, the .gitignore file one
+}
+
+// ParsePattern parses a pattern. It returns false for a blank line or a comment, which match nothing,
+// and an error for a malformed pattern such as an unterminated character class.
+func ParsePattern(s string) (Pattern, bool, error) {
+	p := Pattern{source: s}
+
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return p, false, nil
+	}
+
+	if strings.HasPrefix(s, "!") {
+		p.negate = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, `\!`) || strings.HasPrefix(s, `\#`) {
+		s = s[1:]
+	}
+
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly = true
+		s = strings.TrimRight(s, "/")
+	}
+
+	p.anchored = strings.Contains(s, "/")
+	s = strings.TrimPrefix(s, "/")
+	if s == "" {
+		return p, false, nil
+	}
+
+	p.parts = strings.Split(s, "/")
+	if !p.anchored && p.parts[0] != "**" {
+		p.parts = append([]string{"**"}, p.parts...)
+	}
+
+	for _, part := range p.parts {
+		if _, err := path.Match(part, ""); err != nil {
+			return p, false, fmt.Errorf("invalid pattern %q: %w", p.source, err)
+		}
+	}
+
+	return p, true, nil
+}
+
+// Negate reports whether the pattern re-adds the paths it matches.
+func (p Pattern) Negate() bool {
+	return p.negate
+}
+
+// String returns the pattern as written.
+func (p Pattern) String() string {
+	return p.source
+}
+
+// Match reports whether the pattern matches the path itself, ignoring its parents. The path is relative
+// to the project directory.
+func (p Pattern) Match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	return p.matchComponents(splitPath(rel))
+}
+
+// matchComponents matches the path components, relative to the project directory, against the pattern.
+func (p Pattern) matchComponents(components []string) bool {
+	if len(p.base) > 0 {
+		if len(components) <= len(p.base) || !slices.Equal(components[:len(p.base)], p.base) {
+			return false
+		}
+		components = components[len(p.base):]
+	}
+
+	return matchParts(p.parts, components)
+}
+
+// matchBelow reports whether the pattern may match a path below the directory given as components.
+func (p Pattern) matchBelow(dir []string) bool {
+	parts := p.parts
+	for _, component := range dir {
+		if len(parts) == 0 {
+			return false
+		}
+		if parts[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(parts[0], component); !ok {
+			return false
+		}
+		parts = parts[1:]
+	}
+
+	return len(parts) > 0
+}
+
+// matchParts matches the path components against the pattern components.
+func matchParts(parts, components []string) bool {
+	for len(parts) > 0 {
+		if parts[0] == "**" {
+			rest := parts[1:]
+			if len(rest) == 0 {
+				// A trailing "**" matches everything inside, not the directory itself
+				return len(components) > 0
+			}
+			for i := 0; i <= len(components); i++ {
+				if matchParts(rest, components[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(components) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(parts[0], components[0]); !ok {
+			return false
+		}
+		parts, components = parts[1:], components[1:]
+	}
+
+	return len(components) == 0
+}
+
+// splitPath returns the components of a relative path, using either separator.
+func splitPath(rel string) []string {
+	rel = strings.Trim(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return nil
+	}
+
+	return strings.Split(rel, "/")
+}
+
+// Matcher applies patterns in order, the last one matching a path or one of its parent directories
+// deciding whether the path is excluded. The patterns of the .gitignore files added to the matcher are
+// applied first, so that the configured patterns can re-add ignored paths.
+type Matcher struct {
+	patterns  []Pattern
+	gitignore []Pattern // In the order git applies them: .git/info/exclude, then from the top directory down
+	root      []string  // Components of the directory the .gitignore patterns are limited to
+}
+
+// New parses the patterns into a Matcher, skipping blank lines and comments.
+func New(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+
+	for _, s := range patterns {
+		p, ok, err := ParsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+
+	return m, nil
+}
+
+// Patterns returns the parsed patterns, in order.
+func (m *Matcher) Patterns() []Pattern {
+	return m.patterns
+}
+
+// Excluded reports whether the path, relative to the project directory, is excluded. A path inside an
+// excluded directory is excluded as well, unless a later pattern re-adds it or one of its parents.
+func (m *Matcher) Excluded(rel string, isDir bool) bool {
+	components := splitPath(rel)
+	if len(components) == 0 {
+		return false
+	}
+
+	excluded := m.gitignored(components, isDir)
+	for _, p := range m.patterns {
+		if p.negate == !excluded {
+			continue // The pattern would not change the result
+		}
+		if p.matchComponents(components) && (!p.dirOnly || isDir) || p.matchParent(components) {
+			excluded = !p.negate
+		}
+	}
+
+	return excluded
+}
+
+// matchParent reports whether the pattern matches one of the parent directories of the path.
+func (p Pattern) matchParent(components []string) bool {
+	for i := len(components) - 1; i > 0; i-- {
+		if p.matchComponents(components[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Descend reports whether an excluded directory must still be walked, because an anchored pattern
+// re-adds a path inside it, such as !var/cache/pools inside an excluded var directory. Patterns
+// re-adding paths at any depth, such as !*.php, do not make excluded directories walked.
+func (m *Matcher) Descend(rel string) bool {
+	dir := splitPath(rel)
+
+	for _, p := range m.patterns {
+		if p.negate && p.anchored && p.matchBelow(dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetGitignoreRoot limits the .gitignore patterns to the paths inside the directory, relative to the project
+// directory: neither the directory nor its parents are ignored by them, so that a directory the user asked
+// to watch, such as a vendor package below an ignored vendor directory, is walked anyway.
+func (m *Matcher) SetGitignoreRoot(dir string) {
+	m.root = splitPath(dir)
+}
+
+// gitignored reports whether the .gitignore patterns ignore the path: like git, a path is ignored when the
+// last pattern matching it ignores it, or when one of its parent directories below the root is ignored.
+func (m *Matcher) gitignored(components []string, isDir bool) bool {
+	if len(m.gitignore) == 0 || len(components) <= len(m.root) || !slices.Equal(components[:len(m.root)], m.root) {
+		return false
+	}
+
+	for i := len(m.root) + 1; i <= len(components); i++ {
+		dir := i < len(components) || isDir
+
+		ignored := false
+		for _, p := range m.gitignore {
+			if p.negate == ignored && (!p.dirOnly || dir) && p.matchComponents(components[:i]) {
+				ignored = !p.negate
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddGitignore adds the patterns of a .gitignore file, relative to dir, the directory of the file relative
+// to the project directory. A missing file adds nothing. The files must be added from the top directory
+// down, as the patterns of a nested file take precedence over the ones of its parent directories.
+func (m *Matcher) AddGitignore(file, dir string) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	base := splitPath(dir)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		p, ok, err := ParsePattern(scanner.Text())
+		if err != nil {
+			// Like git, skip the malformed patterns rather than ignoring the whole file
+			continue
+		}
+		if ok {
+			p.base = base
+			m.gitignore = append(m.gitignore, p)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,142 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Excluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "Name matches a component", patterns: []string{"var"}, path: "src/var", isDir: true, want: true},
+		{name: "Name does not match a substring", patterns: []string{"var"}, path: "src/Service/Variant", isDir: true, want: false},
+		{name: "Dot name does not match a prefix", patterns: []string{".git"}, path: "src/.github-integration", isDir: true, want: false},
+		{name: "Inside an excluded directory", patterns: []string{"var"}, path: "src/var/cache/file.php", want: true},
+		{name: "Wildcard on files", patterns: []string{"*.log"}, path: "src/Blog/debug.log", want: true},
+		{name: "Anchored path", patterns: []string{"templates/emails/*.mjml"}, path: "templates/emails/welcome.mjml", want: true},
+		{name: "Anchored path elsewhere", patterns: []string{"templates/emails/*.mjml"}, path: "templates/other/emails/welcome.mjml", want: false},
+		{name: "Leading slash", patterns: []string{"/src"}, path: "lib/src", isDir: true, want: false},
+		{name: "Double star matches no directory", patterns: []string{"src/**/Fixtures"}, path: "src/Fixtures", isDir: true, want: true},
+		{name: "Double star matches directories", patterns: []string{"src/**/Fixtures"}, path: "src/Blog/Admin/Fixtures/Posts.php", want: true},
+		{name: "Trailing double star", patterns: []string{"config/**"}, path: "config", isDir: true, want: false},
+		{name: "Directory only on a file", patterns: []string{"cache/"}, path: "src/cache", want: false},
+		{name: "Directory only on a directory", patterns: []string{"cache/"}, path: "src/cache", isDir: true, want: true},
+		{name: "Re-added", patterns: []string{"src/**/Fixtures", "!src/Core/Fixtures"}, path: "src/Core/Fixtures/Users.php", want: false},
+		{name: "Re-added then excluded", patterns: []string{"!src/Core/Fixtures", "src/**/Fixtures"}, path: "src/Core/Fixtures/Users.php", want: true},
+		{name: "Re-added file", patterns: []string{"*.php", "!Kernel.php"}, path: "src/Kernel.php", want: false},
+		{name: "Escaped exclamation mark", patterns: []string{`\!important`}, path: "src/!important", want: true},
+		{name: "Comment", patterns: []string{"# src"}, path: "src", isDir: true, want: false},
+		{name: "Project directory", patterns: []string{"*"}, path: ".", isDir: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Excluded(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Matcher.Excluded(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_Descend(t *testing.T) {
+	m, err := New([]string{"var", "config/generated", "!config/generated/keep", "!*.php"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{dir: "config/generated", want: true},
+		{dir: "config/generated/other", want: false},
+		{dir: "src/var", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			if got := m.Descend(tt.dir); got != tt.want {
+				t.Errorf("Matcher.Descend(%q) = %v, want %v", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	if _, err := New([]string{"src/[a-"}); err == nil {
+		t.Error("New() error = nil, want an invalid pattern error")
+	}
+}
+
+func TestMatcher_AddGitignore(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	m, err := New([]string{"!config/jwt/public.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []struct{ name, content, dir string }{
+		{name: "exclude", content: "*.swp\n", dir: ""},
+		{name: "root", content: "# Generated\n/var/\n*.pem\n!config/jwt/dev.pem\ncache/\n", dir: ""},
+		{name: "src", content: "Generated/\n!Generated/Kept.php\n/Local.php\n", dir: "src"},
+	} {
+		if err = m.AddGitignore(write(file.name, file.content), file.dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = m.AddGitignore(filepath.Join(dir, "missing"), "templates"); err != nil {
+		t.Errorf("Matcher.AddGitignore() on a missing file error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "src/Kernel.php.swp", want: true},
+		{path: "var", isDir: true, want: true},
+		{path: "src/var", isDir: true, want: false},
+		{path: "config/jwt/private.pem", want: true},
+		{path: "config/jwt/dev.pem", want: false},
+		{path: "config/jwt/public.pem", want: false},
+		{path: "translations/cache", want: false},
+		{path: "translations/cache/messages.php", want: true},
+		{path: "src/Generated/Entity.php", want: true},
+		{path: "src/Generated/Kept.php", want: true},
+		{path: "src/Local.php", want: true},
+		{path: "src/Controller/Local.php", want: false},
+		{path: "Generated/Entity.php", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Excluded(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Matcher.Excluded(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+	// Limited to an ignored directory, the patterns only ignore the paths inside it
+	m.SetGitignoreRoot("var/cache/pools")
+	for path, want := range map[string]bool{"var/cache/pools": false, "var/cache/pools/app.php": false, "var/cache/pools/cache/app.php": true, "var/log/dev.log": false} {
+		if got := m.Excluded(path, false); got != want {
+			t.Errorf("Matcher.Excluded(%q) below the root = %v, want %v", path, got, want)
+		}
+	}
+}
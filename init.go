@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+// addInitFlags registers the flags of the init command.
+func addInitFlags(flags *flag.FlagSet) {
+	flags.Bool("print", false, fmt.Sprintf("print the config file instead of writing %s (default: false)", structs.ConfigFile))
+	flags.Bool("overwrite", false, fmt.Sprintf("replace an existing %s (default: false)", structs.ConfigFile))
+}
+
+// runInit detects the layout of the project and writes it to a commented config file.
+func runInit(flags *flag.FlagSet) int {
+	projectDir, err := GetProjectDir(flags.Args())
+	if err != nil {
+		PrintError(fmt.Errorf("%w: %w", structs.ErrProjectNotFound, err))
+		return ExitProjectNotFound
+	}
+
+	layout, err := structs.Detect(projectDir)
+	if err != nil {
+		PrintError(err)
+		return ExitFailure
+	}
+
+	content := layout.Render()
+	if flags.Lookup("print").Value.String() == "true" {
+		fmt.Print(content)
+		return ExitOK
+	}
+
+	path := filepath.Join(projectDir, structs.ConfigFile)
+	if _, err = os.Stat(path); err == nil && flags.Lookup("overwrite").Value.String() != "true" {
+		PrintError(fmt.Errorf("%s already exists, pass --overwrite to replace it or --print to see the detected settings", path))
+		return ExitFailure
+	}
+
+	if err = os.WriteFile(path, []byte(content), 0o644); err != nil {
+		PrintError(err)
+		return ExitFailure
+	}
+
+	fmt.Println()
+	fmt.Println(" > Config file written: " + color.New(color.FgGreen).Sprintf(path))
+	for _, line := range FormatLayout(layout) {
+		fmt.Println(line)
+	}
+	fmt.Println(fmt.Sprintf(" > Run %s to check the project setup.", color.GreenString("%s doctor %s", filepath.Base(os.Args[0]), projectDir)))
+
+	return ExitOK
+}
+
+// FormatLayout returns one line per detected setting, the settings left to their default value last.
+func FormatLayout(layout structs.Layout) []string {
+	var lines, missing []string
+
+	for _, path := range append(layout.Dirs, layout.ConsolePath) {
+		if !path.Found {
+			missing = append(missing, fmt.Sprintf("   %s %s: %s not found, create it or set an existing path", color.New(color.FgHiRed).Sprint("!"), path.Key, path.Path))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("   %s %s: %s (%s)", color.New(color.FgGreen).Sprint("✔"), path.Key, path.Path, path.Source))
+	}
+
+	if len(layout.Excludes) > 0 {
+		lines = append(lines, fmt.Sprintf("   %s exclude: %s (.gitignore)", color.New(color.FgGreen).Sprint("✔"), strings.Join(layout.Excludes, ", ")))
+	}
+	if len(layout.Vendors) > 0 {
+		lines = append(lines, fmt.Sprintf("   %s vendors: %s (path repositories)", color.New(color.FgGreen).Sprint("✔"), strings.Join(layout.Vendors, ", ")))
+	}
+
+	return append(lines, missing...)
+}
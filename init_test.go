@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+func TestRun_Init(t *testing.T) {
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+	devNull, _ := os.Open(os.DevNull)
+	defer devNull.Close()
+	os.Stdout = devNull
+
+	config := newFakeProject(t, "")
+	path := filepath.Join(config.DirSymfonyProject, structs.ConfigFile)
+
+	if got := Run([]string{"init", config.DirSymfonyProject}); got != ExitOK {
+		t.Fatalf("Run(init) = %v, want %v", got, ExitOK)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), "console_path: bin/console") {
+		t.Errorf("Run(init) wrote %q, %v, want the detected console path", data, err)
+	}
+
+	if got := Run([]string{"init", config.DirSymfonyProject}); got != ExitFailure {
+		t.Errorf("Run(init) = %v, want %v for an existing config file", got, ExitFailure)
+	}
+	if got := Run([]string{"init", "--overwrite", config.DirSymfonyProject}); got != ExitOK {
+		t.Errorf("Run(init --overwrite) = %v, want %v", got, ExitOK)
+	}
+}
+
+func TestFormatLayout(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = old }()
+
+	layout := structs.Layout{
+		Dirs: []structs.DetectedPath{
+			{Key: "dir_src", Path: "app", Source: "autoload.psr-4", Found: true},
+			{Key: "dir_translations", Path: "translations", Source: "default"},
+		},
+		ConsolePath: structs.DetectedPath{Key: "console_path", Path: "bin/console", Source: "existing path", Found: true},
+		Vendors:     []string{"acme/foo"},
+	}
+
+	got := strings.Join(FormatLayout(layout), "\n")
+	want := strings.Join([]string{
+		"   ✔ dir_src: app (autoload.psr-4)",
+		"   ✔ console_path: bin/console (existing path)",
+		"   ✔ vendors: acme/foo (path repositories)",
+		"   ! dir_translations: translations not found, create it or set an existing path",
+	}, "\n")
+
+	if got != want {
+		t.Errorf("FormatLayout() = %q, want %q", got, want)
+	}
+}
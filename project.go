@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+// GetProjectDir returns the path to the Symfony project directory based on the positional arguments
+// left once the subcommand flags are parsed.
+// If the provided path is relative, it joins it with the current working directory.
+// If the provided path does not exist, it returns an error.
+// Without argument, the project is searched with FindProjectDir from the current working directory upwards,
+// so that the tool can be started from any subdirectory of the project.
+// Otherwise, it returns the path to the Symfony project directory and nil error.
+func GetProjectDir(args []string) (string, error) {
+	execDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	if len(args) == 0 {
+		return FindProjectDir(execDir)
+	}
+
+	path := args[0]
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(execDir, path)
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// FindProjectDir returns the first directory, from start upwards, that IsProjectDir.
+func FindProjectDir(start string) (string, error) {
+	for dir := start; ; {
+		if IsProjectDir(dir) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no Symfony project found in %s or its parents, pass the project path", start)
+		}
+		dir = parent
+	}
+}
+
+// IsProjectDir reports whether the directory holds a composer.json file and the Symfony console.
+// The console is looked for at the console path set by the config files of the directory and the
+// environment, if any, or at the default one otherwise.
+func IsProjectDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "composer.json")); err != nil {
+		return false
+	}
+
+	var config structs.Config
+	config.Init()
+	_, _ = config.LoadFiles(dir)
+	_, _ = config.LoadEnv(os.Environ())
+
+	info, err := os.Stat(filepath.Join(dir, config.SymfonyConsolePath))
+
+	return err == nil && !info.IsDir()
+}
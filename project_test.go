@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetProjectDir(t *testing.T) {
+	execDir, _ := os.Getwd()
+
+	testCases := map[string]struct {
+		args          []string
+		expectedDir   string
+		expectedError string
+	}{
+		"ExistingAbsolutePath": {
+			args:        []string{"/tmp"},
+			expectedDir: "/tmp",
+		},
+		"ExistingRelativePath": {
+			args:        []string{"symfony"},
+			expectedDir: filepath.Join(execDir, "symfony"),
+		},
+		"MissingPath": {
+			args:          []string{"missingDir"},
+			expectedError: fmt.Sprintf("stat %s: no such file or directory", filepath.Join(execDir, "missingDir")),
+		},
+	}
+
+	for testName, tc := range testCases {
+		result, err := GetProjectDir(tc.args)
+		if err != nil && err.Error() != tc.expectedError {
+			t.Errorf("%s: unexpected error, expected %v, but got: %v", testName, tc.expectedError, err)
+		}
+		if tc.expectedDir != result {
+			t.Errorf("%s: unexpected directory return, expected %v, but got: %v", testName, tc.expectedDir, result)
+		}
+	}
+}
+
+func TestFindProjectDir(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	for name, content := range map[string]string{
+		"project/composer.json":        "{}",
+		"project/bin/console":          "#!/usr/bin/env php",
+		"project/src/Controller/.keep": "",
+		"custom/composer.json":         "{}",
+		"custom/tools/console":         "#!/usr/bin/env php",
+		"custom/.cache-warmer.yaml":    "console_path: tools/console\n",
+		"custom/src/.keep":             "",
+		"library/composer.json":        "{}",
+		"library/src/.keep":            "",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		start   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Project directory", start: project, want: project},
+		{name: "Subdirectory", start: filepath.Join(project, "src", "Controller"), want: project},
+		{name: "Configured console path", start: filepath.Join(root, "custom", "src"), want: filepath.Join(root, "custom")},
+		{name: "No console", start: filepath.Join(root, "library", "src"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindProjectDir(tt.start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindProjectDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FindProjectDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetProjectDir_NoArguments(t *testing.T) {
+	execDir, _ := os.Getwd()
+	defer os.Chdir(execDir)
+
+	project := t.TempDir()
+	_ = os.WriteFile(filepath.Join(project, "composer.json"), []byte("{}"), 0o644)
+	_ = os.MkdirAll(filepath.Join(project, "bin", "cache"), os.ModePerm)
+	_ = os.WriteFile(filepath.Join(project, "bin", "console"), []byte("#!/usr/bin/env php"), 0o755)
+
+	if err := os.Chdir(filepath.Join(project, "bin", "cache")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetProjectDir(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.EvalSymlinks(project); got != want && got != project {
+		t.Errorf("GetProjectDir() = %v, want %v", got, project)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// States of a running watch command.
+const (
+	StateIdle    = "idle"    // Waiting for changes
+	StateWarming = "warming" // Running a warmup
+)
+
+// Status is the state of a running watch command. It is written to a file in the status directory of the
+// user, named after the project directory, so that the status command can read it from another process.
+type Status struct {
+	PID          int           `json:"pid"`
+	Version      string        `json:"version"`
+	ProjectDir   string        `json:"project_dir"`
+	Started      time.Time     `json:"started"`
+	Watcher      string        `json:"watcher"`       // inotify or polling
+	FilesWatched int           `json:"files_watched"` // Number of files in the last snapshot
+	State        string        `json:"state"`         // idle or warming
+	Warmups      int           `json:"warmups"`       // Number of warmups started
+	LastWarmup   *WarmupStatus `json:"last_warmup,omitempty"`
+}
+
+// WarmupStatus describes the last warmup started by a watch command.
+type WarmupStatus struct {
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"` // Zero while the warmup runs
+	Result   string        `json:"result"`   // Empty while the warmup runs, then done, failed or canceled
+	Error    string        `json:"error,omitempty"`
+}
+
+// StatusFile keeps the status file of a watch command up to date. A nil *StatusFile does nothing,
+// so that MainLoop can run without one.
+type StatusFile struct {
+	path   string
+	mu     sync.Mutex
+	status Status
+}
+
+// StatusDir returns the directory of the status files: $XDG_RUNTIME_DIR/cache-warmer when the variable is
+// set, the cache-warmer directory of the user cache directory otherwise. Unlike the shared temporary
+// directory, other users can neither read nor replace the files there.
+func StatusDir() (string, error) {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		var err error
+		if base, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(base, "cache-warmer"), nil
+}
+
+// StatusPath returns the path of the status file of the given project directory.
+func StatusPath(projectDir string) (string, error) {
+	dir, err := StatusDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(projectDir))
+
+	return filepath.Join(dir, fmt.Sprintf("status-%s.json", hex.EncodeToString(sum[:])[:12])), nil
+}
+
+// NewStatusFile writes the initial status to the status file of status.ProjectDir, creating the status
+// directory, only accessible to the user, if needed.
+func NewStatusFile(status Status) (*StatusFile, error) {
+	path, err := StatusPath(status.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f := &StatusFile{path: path, status: status}
+
+	return f, f.write()
+}
+
+// Update applies fn to the status and rewrites the file. Write errors are ignored: the status is
+// informative and must never stop the watcher.
+func (f *StatusFile) Update(fn func(status *Status)) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fn(&f.status)
+	_ = f.write()
+}
+
+// Remove deletes the status file once the watch command stops.
+func (f *StatusFile) Remove() {
+	if f == nil {
+		return
+	}
+
+	_ = os.Remove(f.path)
+}
+
+// write replaces the status file through a rename, so that a reader never sees a partial file. The
+// temporary file is created next to the status file, with a unique name.
+func (f *StatusFile) write() error {
+	data, err := json.MarshalIndent(f.status, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// ReadStatus reads the status file of the given project directory. It returns os.ErrNotExist when no
+// watch command runs on the project, including when the file was left behind by a process that died.
+func ReadStatus(projectDir string) (Status, error) {
+	var status Status
+
+	path, err := StatusPath(projectDir)
+	if err != nil {
+		return status, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return status, err
+	}
+
+	if err = json.Unmarshal(data, &status); err != nil {
+		return status, fmt.Errorf("invalid status file %s: %w", path, err)
+	}
+
+	if !processAlive(status.PID) {
+		return status, os.ErrNotExist
+	}
+
+	return status, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusFile(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	projectDir := t.TempDir()
+
+	status, err := NewStatusFile(Status{PID: os.Getpid(), ProjectDir: projectDir, State: StateIdle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer status.Remove()
+
+	status.Update(func(s *Status) {
+		s.State = StateWarming
+		s.Warmups++
+	})
+
+	got, err := ReadStatus(projectDir)
+	if err != nil {
+		t.Fatalf("ReadStatus() error = %v", err)
+	}
+	if got.State != StateWarming || got.Warmups != 1 || got.ProjectDir != projectDir {
+		t.Errorf("ReadStatus() = %+v, want the updated status", got)
+	}
+
+	dir, _ := StatusDir()
+	if info, err := os.Stat(dir); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0o700) {
+		t.Errorf("status directory = %v (%v), want a directory only accessible to the user", info, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("status directory holds %d file(s), want the status file only", len(entries))
+	}
+
+	status.Remove()
+	if _, err = ReadStatus(projectDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadStatus() error = %v, want %v once removed", err, os.ErrNotExist)
+	}
+}
+
+func TestReadStatus_DeadProcess(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	projectDir := t.TempDir()
+
+	status, err := NewStatusFile(Status{PID: 1 << 30, ProjectDir: projectDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer status.Remove()
+
+	if _, err = ReadStatus(projectDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadStatus() error = %v, want %v for a dead process", err, os.ErrNotExist)
+	}
+}
+
+func TestStatusDir(t *testing.T) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name       string
+		runtimeDir string
+		want       string
+	}{
+		{name: "Runtime directory", runtimeDir: "/run/user/1000", want: filepath.Join("/run/user/1000", "cache-warmer")},
+		{name: "User cache directory", runtimeDir: "", want: filepath.Join(cacheDir, "cache-warmer")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_RUNTIME_DIR", tt.runtimeDir)
+
+			got, err := StatusDir()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("StatusDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusFile_Nil(t *testing.T) {
+	var status *StatusFile
+
+	status.Update(func(s *Status) { t.Error("Update() called fn on a nil status file") })
+	status.Remove()
+}
+
+func TestFormatStatus(t *testing.T) {
+	started := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	status := Status{
+		PID:          42,
+		ProjectDir:   "/app",
+		Started:      started,
+		Watcher:      "inotify",
+		FilesWatched: 12,
+		State:        StateIdle,
+		Warmups:      2,
+		LastWarmup:   &WarmupStatus{Started: started.Add(time.Minute), Duration: 2 * time.Second, Result: "failed", Error: "symfony command failed"},
+	}
+
+	got := strings.Join(FormatStatus(status, started.Add(time.Hour)), "\n")
+	for _, want := range []string{"pid 42", "inotify", "Idle", "failed at 10:01:00 in 2 second(s)", "symfony command failed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("FormatStatus() = %q, want it to contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// processAlive reports whether a process with the given pid is running. On Windows, FindProcess
+// opens a handle to the process and fails when it does not exist.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+
+	return true
+}
@@ -1,69 +1,159 @@
 package structs
 
-import "time"
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
 
 // Symfony default parameters for Symfony/Flex.
 const (
+	ChangesLimit    = 10 // Number of changed files printed before each warmup
+	CommandTimeout  = 0  // Console commands run without time limit by default
 	ConsolePath     = "bin/console"
 	ClearCache      = false
+	Debounce        = 300 * time.Millisecond // Quiet period before warming up after a change
 	Debug           = true
 	Env             = "dev"
 	VendorWatch     = false
+	Verbose         = false
 	DirConfig       = "config"
 	DirMigrations   = "migrations"
 	DirSrc          = "src"
 	DirTemplates    = "templates"
 	DirTranslations = "translations"
 	DirVendor       = "vendor"
+	Executor        = ExecutorDirect
 	ForceClearCache = false
+	Gitignore       = false
+	GracePeriod     = 50 * time.Millisecond // Delay before confirming changes when the debounce is disabled
+	HashContent     = false
+	Poll            = false
 	PoolsProvided   = false
 	SleepTime       = 30 * time.Millisecond // Watcher process sleep time
+	Strategy        = StrategyDirs
+	WarmupPolicy    = PolicyQueue
+)
+
+// Executors running the Symfony console.
+const (
+	ExecutorDirect  = "direct"  // Run bin/console on the host
+	ExecutorCompose = "compose" // Run bin/console with docker compose exec <service>
+	ExecutorDocker  = "docker"  // Run bin/console with docker exec <container>
+	ExecutorSymfony = "symfony" // Run symfony console
+)
+
+// Policies applied when files change while a warmup is running.
+const (
+	PolicyQueue   = "queue"   // Let the running warmup finish, then run one follow-up warmup
+	PolicyRestart = "restart" // Kill the running warmup and start over
+)
+
+// Strategies building the watch set.
+const (
+	StrategyDirs = "dirs" // Watch the configured Symfony directories
+	StrategyMeta = "meta" // Watch the resources listed by the .meta files of the Symfony cache
 )
 
 // DefaultExcludedDirs contains the directories that should be excluded by default.
 var DefaultExcludedDirs = []string{".git", ".github", "node_modules"}
 
+// DefaultTempFiles contains the name patterns of the temporary files written by editors and tools:
+// PhpStorm safe writes, Vim swap files, backups and write test file, Emacs lock and auto-save files,
+// and the macOS Finder metadata.
+var DefaultTempFiles = []string{"*___jb_tmp___", "*___jb_old___", "*.swp", "*.swo", "*.swx", "*~", "4913", ".#*", `\#*#`, ".DS_Store"}
+
 // Config holds all the parameters needed for the application. The YAML tags
-// represent the keys in the Symfony custom config file, which will override
-// these default values.
+// represent the keys in the project config files, which will override
+// these default values. The project directory locates these files, so it
+// cannot be set from them.
 type Config struct {
-	ClearCache             bool // Clear cache instead of only warmup
-	DirMigrations          string
-	DirSymfonyConfig       string        // Directory where configuration files are stored
-	DirSymfonyProject      string        // The main Symfony project directory
-	DirSymfonySrc          string        // Directory where source code is stored
-	DirSymfonyTemplates    string        // Directory where template files are stored
-	DirSymfonyTranslations string        // Directory where translation files are stored
-	DirSymfonyVendor       string        // Directory where vendor code is stored
-	DirsExclude            []string      // Directories to exclude from monitoring
-	ForceClearCache        bool          // Force cache removal using rm -rf var/cache
-	Pools                  []string      // List of pools to watch
-	PoolsProvided          bool          // Whether the --pools flag was provided
-	SleepTime              time.Duration // Sleep time between filesystem checks
-	SymfonyConsolePath     string        // Relative path to the Symfony console
-	SymfonyDebug           bool          // APP_DEBUG parameter
-	SymfonyEnv             string        // APP_ENV parameter
-	VendorList             []string      // List of specific vendor directories to watch
-	VendorWatch            bool          // Whether to watch vendor directories
+	ChangesLimit           int           `yaml:"changes_limit"`     // Maximum number of changed files printed before a warmup
+	ClearCache             bool          `yaml:"clear_cache"`       // Clear cache instead of only warmup
+	CommandTimeout         time.Duration `yaml:"timeout"`           // Maximum duration of each console command, 0 for no limit
+	Container              string        `yaml:"container"`         // Docker compose service or docker container running the console
+	Debounce               time.Duration `yaml:"debounce"`          // Quiet period merging bursts of changes
+	DirMigrations          string        `yaml:"dir_migrations"`    // Directory where migrations are stored
+	DirSymfonyConfig       string        `yaml:"dir_config"`        // Directory where configuration files are stored
+	DirSymfonyProject      string        `yaml:"-"`                 // The main Symfony project directory
+	DirSymfonySrc          string        `yaml:"dir_src"`           // Directory where source code is stored
+	DirSymfonyTemplates    string        `yaml:"dir_templates"`     // Directory where template files are stored
+	DirSymfonyTranslations string        `yaml:"dir_translations"`  // Directory where translation files are stored
+	DirSymfonyVendor       string        `yaml:"dir_vendor"`        // Directory where vendor code is stored
+	DirsExclude            []string      `yaml:"exclude"`           // Gitignore-style patterns of paths to exclude from monitoring
+	Executor               string        `yaml:"executor"`          // How the console is run: direct, compose, docker or symfony
+	ForceClearCache        bool          `yaml:"force_clear_cache"` // Force cache removal using rm -rf var/cache
+	Gitignore              bool          `yaml:"gitignore"`         // Skip the paths ignored by the .gitignore files of the project
+	HashContent            bool          `yaml:"hash"`              // Compare file contents instead of modification times
+	PathMap                string        `yaml:"path_map"`          // Host to container project path mapping, as host:container
+	Poll                   bool          `yaml:"poll"`              // Poll the filesystem instead of using inotify
+	Pools                  []string      `yaml:"pools"`             // List of pools to watch
+	PoolsProvided          bool          `yaml:"pools_provided"`    // Whether the --pools flag was provided
+	SleepTime              time.Duration `yaml:"sleep_time"`        // Sleep time between filesystem checks
+	Strategy               string        `yaml:"strategy"`          // How the watch set is built: dirs or meta
+	SymfonyConsolePath     string        `yaml:"console_path"`      // Relative path to the Symfony console
+	SymfonyDebug           bool          `yaml:"debug"`             // APP_DEBUG parameter
+	SymfonyEnv             string        `yaml:"env"`               // APP_ENV parameter
+	TempFiles              []string      `yaml:"temp_files"`        // Name patterns of editor temporary files never watched
+	VendorList             []string      `yaml:"vendors"`           // List of specific vendor directories to watch
+	VendorWatch            bool          `yaml:"vendor_watch"`      // Whether to watch vendor directories
+	Verbose                bool          `yaml:"verbose"`           // Stream the console output while commands run
+	WarmupPolicy           string        `yaml:"policy"`            // What to do when files change during a warmup: queue or restart
+	Watch                  []string      `yaml:"watch"`             // Extra directories and files to watch, optional ones ending with ?
 }
 
 // Init initializes the Config object with default values.
 func (obj *Config) Init() {
+	obj.ChangesLimit = ChangesLimit
 	obj.ClearCache = ClearCache
+	obj.CommandTimeout = CommandTimeout
+	obj.Debounce = Debounce
 	obj.DirMigrations = DirMigrations
 	obj.DirSymfonyConfig = DirConfig
 	obj.DirSymfonySrc = DirSrc
 	obj.DirSymfonyTemplates = DirTemplates
 	obj.DirsExclude = DefaultExcludedDirs
+	obj.Executor = Executor
 	obj.ForceClearCache = ForceClearCache
+	obj.Gitignore = Gitignore
+	obj.HashContent = HashContent
+	obj.Poll = Poll
 	obj.Pools = []string{}
 	obj.PoolsProvided = PoolsProvided
 	obj.SleepTime = SleepTime
+	obj.Strategy = Strategy
 	obj.SymfonyConsolePath = ConsolePath
 	obj.SymfonyDebug = Debug
 	obj.SymfonyEnv = Env
+	obj.TempFiles = DefaultTempFiles
 	obj.DirSymfonyTranslations = DirTranslations
 	obj.DirSymfonyVendor = DirVendor
 	obj.VendorList = []string{}
 	obj.VendorWatch = VendorWatch
+	obj.Verbose = Verbose
+	obj.WarmupPolicy = WarmupPolicy
+	obj.Watch = []string{}
+}
+
+// WatchPath is an extra directory or file of the watch setting.
+type WatchPath struct {
+	Path     string // Relative to the project directory, unless absolute
+	Optional bool   // Whether the path is skipped when missing instead of being an error
+}
+
+// WatchPaths returns the extra directories and files to watch. An entry ending with a question mark,
+// such as modules?, is optional.
+func (obj *Config) WatchPaths() []WatchPath {
+	var paths []WatchPath
+	for _, entry := range obj.Watch {
+		entry = strings.TrimSpace(entry)
+		optional := strings.HasSuffix(entry, "?")
+		entry = strings.TrimSuffix(entry, "?")
+		if entry == "" {
+			continue
+		}
+		paths = append(paths, WatchPath{Path: filepath.Clean(entry), Optional: optional})
+	}
+
+	return paths
 }
@@ -1,6 +1,7 @@
 package structs
 
 import (
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -15,23 +16,35 @@ func TestConfig_Init(t *testing.T) {
 			name:   "DefaultValuesCheck",
 			config: Config{},
 			want: Config{
+				ChangesLimit:           ChangesLimit,
 				ClearCache:             ClearCache,
+				CommandTimeout:         CommandTimeout,
+				Debounce:               Debounce,
 				DirMigrations:          DirMigrations,
 				DirSymfonyConfig:       DirConfig,
 				DirSymfonySrc:          DirSrc,
 				DirSymfonyTemplates:    DirTemplates,
 				DirsExclude:            DefaultExcludedDirs,
+				Executor:               Executor,
 				ForceClearCache:        ForceClearCache,
+				Gitignore:              Gitignore,
+				HashContent:            HashContent,
+				Poll:                   Poll,
 				Pools:                  []string{},
 				PoolsProvided:          PoolsProvided,
 				SleepTime:              SleepTime,
+				Strategy:               Strategy,
 				SymfonyConsolePath:     ConsolePath,
 				SymfonyDebug:           Debug,
 				SymfonyEnv:             Env,
+				TempFiles:              DefaultTempFiles,
 				DirSymfonyTranslations: DirTranslations,
 				DirSymfonyVendor:       DirVendor,
 				VendorList:             []string{},
 				VendorWatch:            VendorWatch,
+				Verbose:                Verbose,
+				WarmupPolicy:           WarmupPolicy,
+				Watch:                  []string{},
 			},
 		},
 	}
@@ -45,3 +58,16 @@ func TestConfig_Init(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_WatchPaths(t *testing.T) {
+	config := Config{Watch: []string{"lib", " modules? ", "config/bundles.php", "?", ""}}
+
+	want := []WatchPath{
+		{Path: "lib"},
+		{Path: "modules", Optional: true},
+		{Path: filepath.Join("config", "bundles.php")},
+	}
+	if got := config.WatchPaths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Config.WatchPaths() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,402 @@
+package structs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Layout is the project layout detected by Detect, from which a config file is written.
+type Layout struct {
+	Symfony     string         // Symfony version constraint of extra.symfony.require, if any
+	Dirs        []DetectedPath // Watched directories, in the dir_* key order of the config file
+	ConsolePath DetectedPath   // Symfony console
+	Psr4Roots   []string       // PSR-4 roots of the autoload section, relative to the project
+	Excludes    []string       // Directories ignored by .gitignore inside the watched directories
+	Vendors     []string       // Packages installed from path repositories
+}
+
+// DetectedPath is a setting detected by Detect, along with where it was found.
+type DetectedPath struct {
+	Key    string // Config key
+	Path   string // Relative to the project directory
+	Source string // Why this path was chosen
+	Found  bool   // Whether the path exists, the default is kept otherwise
+}
+
+// composerJSON holds the composer.json sections read by Detect.
+type composerJSON struct {
+	Autoload struct {
+		PSR4 map[string]json.RawMessage `json:"psr-4"`
+	} `json:"autoload"`
+	Extra        map[string]json.RawMessage `json:"extra"`
+	Repositories json.RawMessage            `json:"repositories"`
+}
+
+// composerRepository is an entry of the composer.json repositories section.
+type composerRepository struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Detect inspects the project to guess its layout: the composer.json autoload PSR-4 roots and
+// extra section, the existing directories, the packages installed from path repositories and the
+// directories ignored by .gitignore. A missing composer.json is not an error, as the existing
+// directories are enough to detect most layouts.
+func Detect(projectDir string) (Layout, error) {
+	var layout Layout
+	var composer composerJSON
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "composer.json"))
+	if err == nil {
+		if err = json.Unmarshal(data, &composer); err != nil {
+			return layout, fmt.Errorf("invalid composer.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return layout, err
+	}
+
+	extra := func(key string) string {
+		var value string
+		_ = json.Unmarshal(composer.Extra[key], &value)
+		return strings.TrimSuffix(value, "/")
+	}
+
+	var symfony struct {
+		Require string `json:"require"`
+	}
+	_ = json.Unmarshal(composer.Extra["symfony"], &symfony)
+	layout.Symfony = symfony.Require
+
+	layout.Psr4Roots = psr4Roots(composer.Autoload.PSR4)
+	srcCandidates := []candidate{{path: extra("src-dir"), source: "extra.src-dir"}}
+	for _, root := range layout.Psr4Roots {
+		srcCandidates = append(srcCandidates, candidate{path: root, source: "autoload.psr-4"})
+	}
+
+	binDir := extra("bin-dir")
+	if binDir != "" {
+		binDir = filepath.Join(binDir, "console")
+	}
+
+	find := func(key, fallback string, candidates ...candidate) DetectedPath {
+		return detectPath(projectDir, key, fallback, candidates)
+	}
+	layout.Dirs = []DetectedPath{
+		find("dir_config", DirConfig, candidate{path: extra("config-dir"), source: "extra.config-dir"}, candidate{path: "app/config"}),
+		find("dir_src", DirSrc, srcCandidates...),
+		find("dir_templates", DirTemplates, candidate{path: "app/Resources/views"}),
+		find("dir_translations", DirTranslations, candidate{path: "app/Resources/translations"}),
+		find("dir_migrations", DirMigrations, candidate{path: "src/Migrations"}),
+	}
+	layout.ConsolePath = find("console_path", ConsolePath, candidate{path: binDir, source: "extra.bin-dir"}, candidate{path: "app/console"})
+
+	var watched []string
+	for _, dir := range layout.Dirs {
+		if dir.Found {
+			watched = append(watched, dir.Path)
+		}
+	}
+	layout.Excludes = gitignoredDirs(projectDir, watched)
+
+	layout.Vendors = pathRepositoryPackages(projectDir, composer.Repositories)
+
+	return layout, nil
+}
+
+// candidate is a possible location of a directory or file.
+type candidate struct {
+	path   string
+	source string // Empty for a well-known location
+}
+
+// detectPath returns the first candidate that exists, trying the default location right after the
+// candidates read from composer.json. The default is returned, not found, when none exists.
+func detectPath(projectDir, key, fallback string, candidates []candidate) DetectedPath {
+	var configured, known []candidate
+	for _, c := range candidates {
+		switch {
+		case c.path == "":
+		case c.source != "":
+			configured = append(configured, c)
+		default:
+			known = append(known, c)
+		}
+	}
+
+	for _, c := range append(append(configured, candidate{path: fallback}), known...) {
+		if _, err := os.Stat(filepath.Join(projectDir, c.path)); err == nil {
+			source := c.source
+			if source == "" {
+				source = "existing path"
+			}
+			return DetectedPath{Key: key, Path: filepath.ToSlash(c.path), Source: source, Found: true}
+		}
+	}
+
+	return DetectedPath{Key: key, Path: fallback, Source: "default"}
+}
+
+// psr4Roots returns the directories of the PSR-4 autoload section, the App\ namespace first and the
+// others sorted by namespace. A namespace may map to a single directory or to a list.
+func psr4Roots(psr4 map[string]json.RawMessage) []string {
+	namespaces := make([]string, 0, len(psr4))
+	for namespace := range psr4 {
+		namespaces = append(namespaces, namespace)
+	}
+	slices.SortFunc(namespaces, func(a, b string) int {
+		switch {
+		case a == `App\`:
+			return -1
+		case b == `App\`:
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	})
+
+	var roots []string
+	for _, namespace := range namespaces {
+		var paths []string
+		if err := json.Unmarshal(psr4[namespace], &paths); err != nil {
+			var path string
+			if json.Unmarshal(psr4[namespace], &path) != nil {
+				continue
+			}
+			paths = []string{path}
+		}
+
+		for _, path := range paths {
+			path = strings.TrimSuffix(strings.TrimPrefix(path, "./"), "/")
+			if path != "" && !slices.Contains(roots, path) {
+				roots = append(roots, path)
+			}
+		}
+	}
+
+	return roots
+}
+
+// gitignoredDirs returns the directories of the project .gitignore that exist inside the watched
+// directories. Patterns containing wildcards and negations are left out, as an exclude names a
+// directory. An unanchored name is kept when a directory of that name exists in a watched directory.
+func gitignoredDirs(projectDir string, watched []string) []string {
+	file, err := os.Open(filepath.Join(projectDir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var dirs []string
+	add := func(dir string) {
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") || strings.ContainsAny(line, "*?[") {
+			continue
+		}
+
+		pattern := strings.TrimSuffix(line, "/")
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+			if isWatchedDir(projectDir, watched, pattern) {
+				add(pattern)
+			}
+			continue
+		}
+
+		for _, root := range watched {
+			if hasDirNamed(filepath.Join(projectDir, root), pattern) {
+				add(pattern)
+				break
+			}
+		}
+	}
+
+	return dirs
+}
+
+// isWatchedDir reports whether the relative path is a directory strictly inside one of the watched directories.
+func isWatchedDir(projectDir string, watched []string, path string) bool {
+	info, err := os.Stat(filepath.Join(projectDir, path))
+	if err != nil || !info.IsDir() {
+		return false
+	}
+
+	for _, root := range watched {
+		if strings.HasPrefix(path, root+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasDirNamed reports whether a directory with the given name exists below root.
+func hasDirNamed(root, name string) bool {
+	found := false
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || found {
+			return filepath.SkipDir
+		}
+		if d.IsDir() && path != root && d.Name() == name {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+
+	return found
+}
+
+// pathRepositoryPackages returns the names of the packages provided by the path repositories of
+// composer.json and installed in the vendor directory, sorted. The repositories section is either
+// a list or an object.
+func pathRepositoryPackages(projectDir string, raw json.RawMessage) []string {
+	var repositories []composerRepository
+	if err := json.Unmarshal(raw, &repositories); err != nil {
+		var named map[string]composerRepository
+		if json.Unmarshal(raw, &named) != nil {
+			return nil
+		}
+		for _, repository := range named {
+			repositories = append(repositories, repository)
+		}
+	}
+
+	var packages []string
+	for _, repository := range repositories {
+		if repository.Type != "path" || repository.URL == "" {
+			continue
+		}
+
+		url := repository.URL
+		if !filepath.IsAbs(url) {
+			url = filepath.Join(projectDir, url)
+		}
+		matches, _ := filepath.Glob(url)
+
+		for _, dir := range matches {
+			var composer struct {
+				Name string `json:"name"`
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+			if err != nil || json.Unmarshal(data, &composer) != nil || composer.Name == "" {
+				continue
+			}
+
+			if _, err = os.Stat(filepath.Join(projectDir, DirVendor, composer.Name)); err == nil && !slices.Contains(packages, composer.Name) {
+				packages = append(packages, composer.Name)
+			}
+		}
+	}
+	slices.Sort(packages)
+
+	return packages
+}
+
+// Render returns the content of a commented config file setting the detected layout.
+func (layout Layout) Render() string {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	line("# cache-warmer configuration, generated by \"cache-warmer init\".")
+	if layout.Symfony != "" {
+		line("# Detected Symfony %s (extra.symfony.require).", layout.Symfony)
+	}
+	line("# Every setting can be overridden in %s, with the %s* environment", LocalConfigFile, EnvPrefix)
+	line("# variables or with the command line flags.")
+
+	line("")
+	line("# Directories watched recursively, relative to the project directory.")
+	for _, dir := range layout.Dirs {
+		renderPath(line, dir)
+	}
+	var otherRoots []string
+	for _, root := range layout.Psr4Roots {
+		if !slices.ContainsFunc(layout.Dirs, func(dir DetectedPath) bool { return dir.Found && dir.Path == root }) {
+			otherRoots = append(otherRoots, root)
+		}
+	}
+	if len(otherRoots) > 0 {
+		line("# Other PSR-4 roots, not watched: %s", strings.Join(otherRoots, ", "))
+	}
+
+	line("")
+	line("# Symfony console, relative to the project directory.")
+	renderPath(line, layout.ConsolePath)
+
+	line("")
+	line("# Paths not watched, in addition to %s, as gitignore-style patterns: a name matches", strings.Join(DefaultExcludedDirs, ", "))
+	line("# at any depth, a path from the project directory, and !pattern re-adds a path.")
+	if len(layout.Excludes) > 0 {
+		line("# Detected from .gitignore.")
+		renderList(line, "exclude", layout.Excludes)
+	} else {
+		line("# exclude: [src/**/Fixtures, '*.log']")
+	}
+	line("# Also skip the paths ignored by the .gitignore files of the project.")
+	line("# gitignore: true")
+
+	line("")
+	line("# Vendor packages watched, relative to %s.", DirVendor)
+	if len(layout.Vendors) > 0 {
+		line("# Detected from the path repositories of composer.json.")
+		renderList(line, "vendors", layout.Vendors)
+	} else {
+		line("# vendors: [acme/foo-bundle]")
+	}
+
+	line("")
+	line("# Extra directories and files watched, relative to the project directory, a trailing ?")
+	line("# marking the optional ones.")
+	line("# watch: [composer.lock, lib, modules?]")
+
+	line("")
+	line("# Watch exactly the resources listed by the .meta files of the Symfony cache once warmed up,")
+	line("# including the vendor bundle configs, rather than the directories above.")
+	line("# strategy: meta")
+
+	return b.String()
+}
+
+// renderPath writes a detected path, commented out when it does not exist.
+func renderPath(line func(format string, args ...any), path DetectedPath) {
+	if !path.Found {
+		line("# %s: %s # Not found, create it or set an existing path", path.Key, quoteScalar(path.Path))
+		return
+	}
+
+	line("%s: %s # %s", path.Key, quoteScalar(path.Path), path.Source)
+}
+
+// renderList writes a block sequence.
+func renderList(line func(format string, args ...any), key string, values []string) {
+	line("%s:", key)
+	for _, value := range values {
+		line("  - %s", quoteScalar(value))
+	}
+}
+
+// quoteScalar single-quotes a value that YAML would not read back as is, such as a value holding an
+// indicator character or read as null.
+func quoteScalar(value string) string {
+	null := slices.Contains([]string{"~", "null", "Null", "NULL"}, value)
+	if value != "" && !null && !strings.ContainsAny(value, ":#'\"[]{},") && strings.TrimSpace(value) == value && !strings.ContainsAny(value[:1], "-!*&?|>%@`") {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,158 @@
+package structs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const detectComposerJSON = `{
+	"autoload": {"psr-4": {"Legacy\\": ["lib/", "old/"], "App\\": "app/"}},
+	"extra": {"symfony": {"require": "7.1.*"}, "bin-dir": "tools"},
+	"repositories": [
+		{"type": "vcs", "url": "https://example.com/repo.git"},
+		{"type": "path", "url": "packages/*"}
+	]
+}`
+
+func TestDetect(t *testing.T) {
+	config := newTestProject(t, map[string]string{
+		"composer.json":                        detectComposerJSON,
+		"app/Kernel.php":                       "<?php",
+		"lib/Legacy.php":                       "<?php",
+		"tools/console":                        "#!/usr/bin/env php",
+		"app/Generated/Proxy.php":              "<?php",
+		"app/Controller/cache/.keep":           "",
+		".gitignore":                           "/var/\n/app/Generated/\ncache/\n*.log\n!/app/Keep/\n",
+		"packages/foo/composer.json":           `{"name": "acme/foo"}`,
+		"packages/bar/composer.json":           `{"name": "acme/bar"}`,
+		"vendor/acme/foo/composer.json":        `{"name": "acme/foo"}`,
+		"vendor/composer/installed.json":       `{"packages": [{"name": "acme/foo"}]}`,
+		"packages/not-a-package/README.md":     "",
+		"vendor/symfony/console/composer.json": "{}",
+	})
+	if err := os.RemoveAll(filepath.Join(config.DirSymfonyProject, DirTranslations)); err != nil {
+		t.Fatal(err)
+	}
+
+	layout, err := Detect(config.DirSymfonyProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if layout.Symfony != "7.1.*" {
+		t.Errorf("Detect() Symfony = %q, want 7.1.*", layout.Symfony)
+	}
+	if want := []string{"app", "lib", "old"}; !reflect.DeepEqual(layout.Psr4Roots, want) {
+		t.Errorf("Detect() Psr4Roots = %v, want %v", layout.Psr4Roots, want)
+	}
+
+	var dirs []string
+	for _, dir := range layout.Dirs {
+		dirs = append(dirs, dir.Key+"="+dir.Path+" "+dir.Source)
+	}
+	wantDirs := []string{
+		"dir_config=config existing path",
+		"dir_src=app autoload.psr-4",
+		"dir_templates=templates existing path",
+		"dir_translations=translations default",
+		"dir_migrations=migrations existing path",
+	}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("Detect() Dirs = %q, want %q", dirs, wantDirs)
+	}
+	if layout.ConsolePath.Path != "tools/console" || layout.ConsolePath.Source != "extra.bin-dir" {
+		t.Errorf("Detect() ConsolePath = %+v, want tools/console from extra.bin-dir", layout.ConsolePath)
+	}
+	if want := []string{"app/Generated", "cache"}; !reflect.DeepEqual(layout.Excludes, want) {
+		t.Errorf("Detect() Excludes = %v, want %v", layout.Excludes, want)
+	}
+	if want := []string{"acme/foo"}; !reflect.DeepEqual(layout.Vendors, want) {
+		t.Errorf("Detect() Vendors = %v, want %v", layout.Vendors, want)
+	}
+}
+
+func TestDetect_NoComposerJSON(t *testing.T) {
+	config := newTestProject(t, nil)
+
+	layout, err := Detect(config.DirSymfonyProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range append(layout.Dirs, layout.ConsolePath) {
+		if !dir.Found {
+			t.Errorf("Detect() %s = %+v, want the default path found", dir.Key, dir)
+		}
+	}
+	if layout.Excludes != nil || layout.Vendors != nil {
+		t.Errorf("Detect() = %+v, want no exclude nor vendor", layout)
+	}
+}
+
+func TestLayout_Render(t *testing.T) {
+	config := newTestProject(t, map[string]string{
+		"composer.json":                  `{"autoload": {"psr-4": {"App\\": "src/", "Tools\\": "tools/"}}, "repositories": {"local": {"type": "path", "url": "packages/foo"}}}`,
+		"src/Generated/Proxy.php":        "<?php",
+		".gitignore":                     "/src/Generated/\n",
+		"packages/foo/composer.json":     `{"name": "acme/foo"}`,
+		"vendor/acme/foo/Bundle.php":     "<?php",
+		"vendor/composer/installed.json": `{"packages": [{"name": "acme/foo"}]}`,
+	})
+
+	layout, err := Detect(config.DirSymfonyProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := layout.Render()
+	for _, want := range []string{"dir_src: src # autoload.psr-4\n", "# Other PSR-4 roots, not watched: tools\n", "exclude:\n  - src/Generated\n", "vendors:\n  - acme/foo\n"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Layout.Render() = %s, want it to contain %q", content, want)
+		}
+	}
+
+	// The rendered file is read back and describes a valid configuration.
+	path := filepath.Join(config.DirSymfonyProject, ConfigFile)
+	if err = os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = config.LoadFile(path); err != nil {
+		t.Fatalf("Config.LoadFile() error = %v", err)
+	}
+	if err = config.Validate(); err != nil {
+		t.Errorf("Config.Validate() error = %v", err)
+	}
+	if !config.VendorWatch || !reflect.DeepEqual(config.VendorList, []string{"acme/foo"}) {
+		t.Errorf("Config.LoadFile() vendors = %v, want acme/foo", config.VendorList)
+	}
+}
+
+func TestQuoteScalar(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "src", want: "src"},
+		{input: "", want: "''"},
+		{input: "a: b", want: "'a: b'"},
+		{input: "it's", want: "'it''s'"},
+		{input: "-dash", want: "'-dash'"},
+		{input: "~", want: "'~'"},
+		{input: "?optional", want: "'?optional'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := quoteScalar(tt.input)
+			if got != tt.want {
+				t.Errorf("quoteScalar() = %v, want %v", got, tt.want)
+			}
+			if parsed, err := parseYAML([]byte("key: " + got)); err != nil || len(parsed) != 1 || parsed[0].Value != tt.input {
+				t.Errorf("parseYAML(quoteScalar()) = %v, %v, want %v", parsed, err, tt.input)
+			}
+		})
+	}
+}
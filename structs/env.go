@@ -0,0 +1,46 @@
+package structs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables setting config keys, e.g. CACHE_WARMER_ENV sets env.
+const EnvPrefix = "CACHE_WARMER_"
+
+// EnvName returns the environment variable setting the given config key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// LoadEnv sets the config keys from the CACHE_WARMER_* variables of the given environment, formatted
+// as "NAME=value" like os.Environ. Values are parsed like the config file scalars, list values being
+// comma-separated. It returns the names of the variables used, sorted.
+func (obj *Config) LoadEnv(environ []string) ([]string, error) {
+	keys := make(map[string]string)
+	for _, key := range Keys() {
+		keys[EnvName(key)] = key
+	}
+
+	var used []string
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+
+		key, ok := keys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown environment variable %s", name)
+		}
+		if err := obj.Set(key, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		used = append(used, name)
+	}
+
+	sort.Strings(used)
+
+	return used, nil
+}
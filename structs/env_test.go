@@ -0,0 +1,67 @@
+package structs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("force_clear_cache"); got != "CACHE_WARMER_FORCE_CLEAR_CACHE" {
+		t.Errorf("EnvName() = %v, want CACHE_WARMER_FORCE_CLEAR_CACHE", got)
+	}
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		environ  []string
+		wantUsed []string
+		check    func(config Config) bool
+		wantErr  bool
+	}{
+		{
+			name:    "No variables",
+			environ: []string{"HOME=/root", "APP_ENV=prod"},
+			check:   func(c Config) bool { return c.SymfonyEnv == Env },
+		},
+		{
+			name:     "Scalars and lists",
+			environ:  []string{"CACHE_WARMER_POOLS=cache.app,cache.system", "CACHE_WARMER_ENV=test", "CACHE_WARMER_DEBOUNCE=1s", "CACHE_WARMER_DEBUG=0"},
+			wantUsed: []string{"CACHE_WARMER_DEBOUNCE", "CACHE_WARMER_DEBUG", "CACHE_WARMER_ENV", "CACHE_WARMER_POOLS"},
+			check: func(c Config) bool {
+				return c.SymfonyEnv == "test" && !c.SymfonyDebug && c.Debounce == time.Second &&
+					c.PoolsProvided && reflect.DeepEqual(c.Pools, []string{"cache.app", "cache.system"})
+			},
+		},
+		{
+			name:     "Value containing an equal sign",
+			environ:  []string{"CACHE_WARMER_PATH_MAP=/a=b:/app"},
+			wantUsed: []string{"CACHE_WARMER_PATH_MAP"},
+			check:    func(c Config) bool { return c.PathMap == "/a=b:/app" },
+		},
+		{name: "Unknown variable", environ: []string{"CACHE_WARMER_ENVIRONMENT=test"}, wantErr: true},
+		{name: "Invalid value", environ: []string{"CACHE_WARMER_TIMEOUT=soon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config Config
+			config.Init()
+
+			used, err := config.LoadEnv(tt.environ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config.LoadEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(used, tt.wantUsed) {
+				t.Errorf("Config.LoadEnv() = %v, want %v", used, tt.wantUsed)
+			}
+			if !tt.check(config) {
+				t.Errorf("Config.LoadEnv() config = %+v", config)
+			}
+		})
+	}
+}
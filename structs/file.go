@@ -0,0 +1,191 @@
+package structs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config files read from the project directory. The local file is meant to be git-ignored
+// and overrides the team-wide one.
+const (
+	ConfigFile      = ".cache-warmer.yaml"
+	LocalConfigFile = ".cache-warmer.local.yaml"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadFiles loads the config file and then the local config file found in the project directory,
+// each one overriding the values set before. Missing files are skipped. It returns the loaded files.
+func (obj *Config) LoadFiles(projectDir string) ([]string, error) {
+	var loaded []string
+
+	for _, name := range []string{ConfigFile, LocalConfigFile} {
+		path := filepath.Join(projectDir, name)
+		err := obj.LoadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return loaded, err
+		}
+		loaded = append(loaded, path)
+	}
+
+	return loaded, nil
+}
+
+// LoadFile reads a YAML config file and sets every key it contains on the Config, in the order of the file.
+func (obj *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	settings, err := parseYAML(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, setting := range settings {
+		if err = obj.Set(setting.Key, setting.Value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// Keys returns the keys of every settable Config field, in field order.
+func Keys() []string {
+	var keys []string
+
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		if key := configType.Field(i).Tag.Get("yaml"); key != "" && key != "-" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Set sets the Config field tagged with the given key. The value is either a string, parsed according
+// to the field type, or a []string for list fields. A string set on a list field is split on commas.
+// Setting the exclude list adds to the excluded directories instead of replacing them, and setting
+// pools or vendors enables clearing the pools or watching the vendors, like the matching flags do.
+func (obj *Config) Set(key string, value any) error {
+	field, ok := obj.field(key)
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+
+	appendList := key == "exclude"
+
+	var err error
+	switch v := value.(type) {
+	case string:
+		err = setString(field, v, appendList)
+	case []string:
+		err = setList(field, v, appendList)
+	default:
+		err = fmt.Errorf("unsupported value type %T", value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %q: %w", key, err)
+	}
+
+	switch key {
+	case "pools":
+		obj.PoolsProvided = true
+		if len(obj.Pools) == 0 {
+			obj.Pools = []string{"--all"}
+		}
+	case "vendors":
+		obj.VendorWatch = len(obj.VendorList) > 0
+	case "force_clear_cache":
+		if obj.ForceClearCache {
+			obj.ClearCache = false
+		}
+	}
+
+	return nil
+}
+
+// field returns the settable field tagged with the given key.
+func (obj *Config) field(key string) (reflect.Value, bool) {
+	value := reflect.ValueOf(obj).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if tag := value.Type().Field(i).Tag.Get("yaml"); tag == key && tag != "-" {
+			return value.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// setString parses the value according to the field type.
+func setString(field reflect.Value, value string, appendList bool) error {
+	switch {
+	case field.Type() == durationType:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(i))
+	case field.Kind() == reflect.Slice:
+		return setList(field, ParseCommaSeparated(value), appendList)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+// setList sets a list field, replacing its values or appending to them.
+func setList(field reflect.Value, values []string, appendList bool) error {
+	if field.Kind() != reflect.Slice || field.Type().Elem().Kind() != reflect.String {
+		return fmt.Errorf("expected a single value, got a list")
+	}
+
+	var list []string
+	if appendList {
+		list = append(list, field.Interface().([]string)...)
+	}
+	list = append(list, values...)
+	if list == nil {
+		list = []string{}
+	}
+	field.Set(reflect.ValueOf(list))
+
+	return nil
+}
+
+// ParseCommaSeparated splits a comma-separated input string and returns an array of strings.
+// If the input string is empty, it returns an empty array.
+func ParseCommaSeparated(input string) []string {
+	if input == "" {
+		return []string{}
+	}
+
+	return strings.Split(input, ",")
+}
@@ -0,0 +1,148 @@
+package structs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   any
+		check   func(config Config) bool
+		wantErr bool
+	}{
+		{name: "String", key: "env", value: "test", check: func(c Config) bool { return c.SymfonyEnv == "test" }},
+		{name: "Bool", key: "debug", value: "false", check: func(c Config) bool { return !c.SymfonyDebug }},
+		{name: "Int", key: "changes_limit", value: "3", check: func(c Config) bool { return c.ChangesLimit == 3 }},
+		{name: "Duration", key: "timeout", value: "2m", check: func(c Config) bool { return c.CommandTimeout == 2*time.Minute }},
+		{name: "List", key: "vendors", value: []string{"acme/foo"}, check: func(c Config) bool {
+			return reflect.DeepEqual(c.VendorList, []string{"acme/foo"}) && c.VendorWatch
+		}},
+		{name: "Comma-separated list", key: "pools", value: "cache.app,cache.system", check: func(c Config) bool {
+			return reflect.DeepEqual(c.Pools, []string{"cache.app", "cache.system"}) && c.PoolsProvided
+		}},
+		{name: "Empty pools", key: "pools", value: []string{}, check: func(c Config) bool {
+			return reflect.DeepEqual(c.Pools, []string{"--all"}) && c.PoolsProvided
+		}},
+		{name: "Exclude appends", key: "exclude", value: []string{"var"}, check: func(c Config) bool {
+			return reflect.DeepEqual(c.DirsExclude, append(append([]string{}, DefaultExcludedDirs...), "var"))
+		}},
+		{name: "Force clear cache", key: "force_clear_cache", value: "true", check: func(c Config) bool {
+			return c.ForceClearCache && !c.ClearCache
+		}},
+		{name: "Unknown key", key: "enviroment", value: "test", wantErr: true},
+		{name: "Project directory", key: "-", value: "/app", wantErr: true},
+		{name: "Invalid bool", key: "poll", value: "sometimes", wantErr: true},
+		{name: "Invalid duration", key: "debounce", value: "300", wantErr: true},
+		{name: "List on a scalar", key: "env", value: []string{"dev", "test"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config Config
+			config.Init()
+			config.ClearCache = true
+
+			err := config.Set(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !tt.check(config) {
+				t.Errorf("Config.Set(%q, %v) = %+v", tt.key, tt.value, config)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var config Config
+	config.Init()
+
+	loaded, err := config.LoadFiles(dir)
+	if err != nil || len(loaded) != 0 {
+		t.Fatalf("Config.LoadFiles() without files = %v, %v", loaded, err)
+	}
+
+	write(ConfigFile, "env: test\ndir_src: lib\nexclude: [var]\n")
+	write(LocalConfigFile, "env: dev\nverbose: true\n")
+
+	loaded, err = config.LoadFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, ConfigFile), filepath.Join(dir, LocalConfigFile)}; !reflect.DeepEqual(loaded, want) {
+		t.Errorf("Config.LoadFiles() = %v, want %v", loaded, want)
+	}
+	if config.SymfonyEnv != "dev" || config.DirSymfonySrc != "lib" || !config.Verbose {
+		t.Errorf("Config.LoadFiles() did not merge the files: %+v", config)
+	}
+	if config.DirSymfonyTemplates != DirTemplates {
+		t.Errorf("Config.LoadFiles() changed an unset key: %+v", config)
+	}
+
+	write(LocalConfigFile, "verbose: maybe\n")
+	if _, err = config.LoadFiles(dir); err == nil {
+		t.Error("Config.LoadFiles() with an invalid value should fail")
+	}
+}
+
+func TestConfig_LoadFile_Order(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		content        string
+		wantClearCache bool
+	}{
+		{name: "Force first", content: "force_clear_cache: true\nclear_cache: true\n", wantClearCache: true},
+		{name: "Force last", content: "clear_cache: true\nforce_clear_cache: true\n", wantClearCache: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, ConfigFile)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The keys are applied in the order of the file, every time
+			for i := 0; i < 20; i++ {
+				var config Config
+				config.Init()
+				if err := config.LoadFile(path); err != nil {
+					t.Fatal(err)
+				}
+				if config.ClearCache != tt.wantClearCache || !config.ForceClearCache {
+					t.Fatalf("Config.LoadFile() clear_cache = %v, force_clear_cache = %v, want %v, true", config.ClearCache, config.ForceClearCache, tt.wantClearCache)
+				}
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+
+	if len(keys) != reflect.TypeOf(Config{}).NumField()-1 {
+		t.Errorf("Keys() = %v, want one key per field except the project directory", keys)
+	}
+	for _, key := range keys {
+		var config Config
+		if _, ok := config.field(key); !ok {
+			t.Errorf("Keys() returned %q which is not settable", key)
+		}
+	}
+}
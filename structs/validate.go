@@ -0,0 +1,402 @@
+package structs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/lettland/cache-warmer/ignore"
+)
+
+// FrontController is the front controller of Symfony/Flex projects, watched when it exists: API-only
+// applications and custom front controllers may not have it.
+const FrontController = "public/index.php"
+
+// Classes of problems found by Config.Validate, matched with errors.Is.
+var (
+	ErrProjectNotFound = errors.New("project directory not found")
+	ErrConsoleNotFound = errors.New("symfony console not found") // Missing or not executable
+)
+
+// problem is a validation problem belonging to one of the classes above.
+type problem struct {
+	message string
+	class   error
+}
+
+// Error returns the message of the problem.
+func (p *problem) Error() string {
+	return p.message
+}
+
+// Unwrap returns the class of the problem.
+func (p *problem) Unwrap() error {
+	return p.class
+}
+
+// ValidationError lists every problem found by Config.Validate.
+type ValidationError struct {
+	Problems []error
+}
+
+// Error returns all the problems on a single line.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap returns the problems, so errors.Is and errors.As look into each of them.
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks the configuration against the project on disk: the watched directories, the extra
+// watched paths, the console, the watched vendor packages and the exclude patterns, along with the values
+// that cannot be checked on disk such as the pools, the executor and the durations.
+// It returns nil or a *ValidationError listing every problem found, not just the first one.
+func (obj *Config) Validate() error {
+	return obj.validate(true)
+}
+
+// ValidateConsole checks the configuration like Validate, leaving out the settings of the watch set: the
+// watched directories, extra paths, vendor packages and exclude patterns. It suits the commands that only
+// run the console, such as a one-shot warmup in an API-only application without templates.
+func (obj *Config) ValidateConsole() error {
+	return obj.validate(false)
+}
+
+// validate checks the configuration, including the settings of the watch set when watchSet is set.
+func (obj *Config) validate(watchSet bool) error {
+	var problems []error
+	addClassProblem := func(class error, format string, args ...any) {
+		problems = append(problems, &problem{message: fmt.Sprintf(format, args...), class: class})
+	}
+
+	if info, err := os.Stat(obj.DirSymfonyProject); err != nil || !info.IsDir() {
+		addClassProblem(ErrProjectNotFound, "project directory %s not found", obj.DirSymfonyProject)
+		return &ValidationError{Problems: problems}
+	}
+
+	if watchSet {
+		problems = append(problems, obj.validateWatchSet()...)
+	}
+
+	if info, err := os.Stat(obj.projectPath(obj.SymfonyConsolePath)); err != nil || info.IsDir() {
+		addClassProblem(ErrConsoleNotFound, "console_path: symfony console %s not found in the project", obj.SymfonyConsolePath)
+	} else if obj.Executor == ExecutorDirect && runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		addClassProblem(ErrConsoleNotFound, "console_path: symfony console %s is not executable, run chmod +x %s", obj.SymfonyConsolePath, obj.SymfonyConsolePath)
+	}
+
+	problems = append(problems, obj.validateValues()...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// validateWatchSet checks the watched directories, the extra watched paths, the watched vendor packages
+// and the exclude patterns.
+func (obj *Config) validateWatchSet() []error {
+	var problems []error
+	addProblem := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	for _, dir := range []struct{ key, path string }{
+		{key: "dir_config", path: obj.DirSymfonyConfig},
+		{key: "dir_src", path: obj.DirSymfonySrc},
+		{key: "dir_templates", path: obj.DirSymfonyTemplates},
+		{key: "dir_translations", path: obj.DirSymfonyTranslations},
+		{key: "dir_migrations", path: obj.DirMigrations},
+	} {
+		if !obj.isDir(dir.path) {
+			addProblem("%s: directory %s not found in the project, set %s (or %s) to an existing directory", dir.key, dir.path, dir.key, EnvName(dir.key))
+		}
+	}
+
+	for _, path := range obj.WatchPaths() {
+		if _, err := os.Stat(obj.projectPath(path.Path)); err != nil && !path.Optional {
+			addProblem("watch: %s not found in the project, create it or mark it optional as %s?", path.Path, path.Path)
+		}
+	}
+
+	if obj.VendorWatch {
+		problems = append(problems, obj.validateVendors()...)
+	}
+
+	return append(problems, obj.validateExcludes()...)
+}
+
+// validateVendors checks that every watched vendor package is installed, suggesting the closest
+// installed package names on typos.
+func (obj *Config) validateVendors() []error {
+	var problems []error
+
+	if !obj.isDir(obj.DirSymfonyVendor) {
+		return append(problems, fmt.Errorf("dir_vendor: vendor directory %s not found, run composer install", obj.DirSymfonyVendor))
+	}
+
+	installed, _ := InstalledPackages(obj.projectPath(filepath.Join(obj.DirSymfonyVendor, "composer", "installed.json")))
+	for _, vendor := range obj.VendorList {
+		if vendor != "" && obj.isDir(filepath.Join(obj.DirSymfonyVendor, vendor)) {
+			continue
+		}
+
+		problem := fmt.Sprintf("vendors: package %q not found in %s", vendor, obj.DirSymfonyVendor)
+		if suggestions := Suggest(vendor, installed); len(suggestions) > 0 {
+			problem += fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, " or "))
+		}
+		problems = append(problems, errors.New(problem))
+	}
+
+	return problems
+}
+
+// validateExcludes checks that every exclude pattern is well-formed.
+func (obj *Config) validateExcludes() []error {
+	var problems []error
+
+	for _, exclude := range obj.DirsExclude {
+		if _, _, err := ignore.ParsePattern(exclude); err != nil {
+			problems = append(problems, fmt.Errorf("exclude: %w", err))
+		}
+	}
+
+	return problems
+}
+
+// Warnings returns the settings that are valid but likely mistaken, which do not prevent the watch from
+// starting: the exclude patterns added to the defaults that match no file or directory of the watched
+// Symfony directories, vendor packages and extra paths, such as a typo or a generated directory not
+// created yet.
+func (obj *Config) Warnings() []error {
+	var patterns []ignore.Pattern
+	for _, exclude := range obj.DirsExclude {
+		if slices.Contains(DefaultExcludedDirs, exclude) || slices.ContainsFunc(patterns, func(p ignore.Pattern) bool { return p.String() == exclude }) {
+			continue
+		}
+		if pattern, ok, err := ignore.ParsePattern(exclude); err == nil && ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	// The same roots as the watch set, a missing one being skipped
+	roots := []string{obj.DirSymfonyConfig, obj.DirSymfonySrc, obj.DirSymfonyTemplates, obj.DirSymfonyTranslations, obj.DirMigrations}
+	if obj.VendorWatch {
+		for _, vendor := range obj.VendorList {
+			roots = append(roots, filepath.Join(obj.DirSymfonyVendor, vendor))
+		}
+	}
+	for _, path := range obj.WatchPaths() {
+		roots = append(roots, path.Path)
+	}
+
+	matched := make([]bool, len(patterns))
+	left := len(patterns)
+	for _, root := range roots {
+		_ = filepath.WalkDir(obj.projectPath(root), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel, _ := filepath.Rel(obj.DirSymfonyProject, path)
+			for i, pattern := range patterns {
+				if !matched[i] && pattern.Match(rel, d.IsDir()) {
+					matched[i] = true
+					left--
+				}
+			}
+			if left == 0 {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+	}
+
+	var warnings []error
+	for i, pattern := range patterns {
+		if !matched[i] {
+			warnings = append(warnings, fmt.Errorf("exclude: %q matches no path in the watched directories", pattern.String()))
+		}
+	}
+
+	return warnings
+}
+
+// validateValues checks the values that do not depend on the project files.
+func (obj *Config) validateValues() []error {
+	var problems []error
+	addProblem := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if obj.PoolsProvided {
+		for _, pool := range obj.Pools {
+			switch {
+			case pool == "--all":
+				if len(obj.Pools) > 1 {
+					addProblem("pools: --all clears every pool and cannot be combined with pool names")
+				}
+			case pool == "" || strings.ContainsAny(pool, " \t"):
+				addProblem("pools: invalid pool name %q", pool)
+			case strings.HasPrefix(pool, "-"):
+				addProblem("pools: unknown option %q, use --all or pool names such as cache.app", pool)
+			}
+		}
+	}
+
+	if obj.WarmupPolicy != PolicyQueue && obj.WarmupPolicy != PolicyRestart {
+		addProblem("policy: invalid policy %q, expected %s or %s", obj.WarmupPolicy, PolicyQueue, PolicyRestart)
+	}
+	if obj.Strategy != StrategyDirs && obj.Strategy != StrategyMeta {
+		addProblem("strategy: invalid strategy %q, expected %s or %s", obj.Strategy, StrategyDirs, StrategyMeta)
+	}
+
+	switch obj.Executor {
+	case ExecutorDirect, ExecutorSymfony:
+	case ExecutorCompose, ExecutorDocker:
+		if obj.Container == "" {
+			addProblem("container: the %s executor requires a container or service name", obj.Executor)
+		}
+	default:
+		addProblem("executor: unknown executor %q, expected %s, %s, %s or %s", obj.Executor, ExecutorDirect, ExecutorCompose, ExecutorDocker, ExecutorSymfony)
+	}
+
+	if obj.PathMap != "" {
+		if host, container, found := strings.Cut(obj.PathMap, ":"); !found || host == "" || container == "" {
+			addProblem("path_map: invalid mapping %q, expected host:container", obj.PathMap)
+		}
+	}
+
+	if obj.SleepTime <= 0 {
+		addProblem("sleep_time: must be positive, got %s", obj.SleepTime)
+	}
+	if obj.Debounce < 0 {
+		addProblem("debounce: must not be negative, got %s", obj.Debounce)
+	}
+	if obj.CommandTimeout < 0 {
+		addProblem("timeout: must not be negative, got %s", obj.CommandTimeout)
+	}
+	if obj.ChangesLimit < 0 {
+		addProblem("changes_limit: must not be negative, got %d", obj.ChangesLimit)
+	}
+	for _, pattern := range obj.TempFiles {
+		if _, _, err := ignore.ParsePattern(pattern); err != nil {
+			addProblem("temp_files: %v", err)
+		}
+	}
+
+	return problems
+}
+
+// projectPath resolves a path relative to the project directory.
+func (obj *Config) projectPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(obj.DirSymfonyProject, path)
+}
+
+// isDir reports whether the path, relative to the project directory, is an existing directory.
+func (obj *Config) isDir(path string) bool {
+	info, err := os.Stat(obj.projectPath(path))
+
+	return err == nil && info.IsDir()
+}
+
+// InstalledPackages returns the names of the packages listed in a Composer installed.json file,
+// in both the Composer 1 (array) and Composer 2 ({"packages": [...]}) formats.
+func InstalledPackages(installedJSON string) ([]string, error) {
+	data, err := os.ReadFile(installedJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	type composerPackage struct {
+		Name string `json:"name"`
+	}
+
+	var packages []composerPackage
+	var composer2 struct {
+		Packages []composerPackage `json:"packages"`
+	}
+	if err = json.Unmarshal(data, &composer2); err == nil {
+		packages = composer2.Packages
+	} else if err = json.Unmarshal(data, &packages); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", installedJSON, err)
+	}
+
+	names := make([]string, 0, len(packages))
+	for _, p := range packages {
+		names = append(names, p.Name)
+	}
+
+	return names, nil
+}
+
+// Suggest returns up to three candidates close to the given name, closest first. A candidate is
+// close when its edit distance is at most a fifth of the name length, or 2 for short names.
+func Suggest(name string, candidates []string) []string {
+	type suggestion struct {
+		candidate string
+		distance  int
+	}
+
+	maxDistance := max(2, len(name)/5)
+	var suggestions []suggestion
+	for _, candidate := range candidates {
+		if distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate)); distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{candidate: candidate, distance: distance})
+		}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return a.distance - b.distance
+	})
+
+	var names []string
+	for i := 0; i < len(suggestions) && i < 3; i++ {
+		names = append(names, suggestions[i].candidate)
+	}
+
+	return names
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
@@ -0,0 +1,253 @@
+package structs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestProject creates a Symfony/Flex project layout with the given extra files and returns its config.
+func newTestProject(t *testing.T, files map[string]string) Config {
+	t.Helper()
+
+	var config Config
+	config.Init()
+	config.DirSymfonyProject = t.TempDir()
+
+	for _, dir := range []string{DirConfig, DirSrc, DirTemplates, DirTranslations, DirMigrations, "src/Controller"} {
+		if err := os.MkdirAll(filepath.Join(config.DirSymfonyProject, dir), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := map[string]string{FrontController: "<?php", ConsolePath: "#!/usr/bin/env php"}
+	for name, content := range files {
+		all[name] = content
+	}
+	for name, content := range all {
+		path := filepath.Join(config.DirSymfonyProject, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return config
+}
+
+func problemsOf(err error) []string {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+
+	var problems []string
+	for _, problem := range validationErr.Problems {
+		problems = append(problems, problem.Error())
+	}
+
+	return problems
+}
+
+func TestConfig_Validate(t *testing.T) {
+	installed := `{"packages": [{"name": "symfony/framework-bundle"}, {"name": "symfony/twig-bundle"}, {"name": "acme/blog-bundle"}]}`
+
+	tests := []struct {
+		name         string
+		files        map[string]string
+		setup        func(config *Config)
+		wantProblems []string
+	}{
+		{
+			name:  "Valid project",
+			setup: func(config *Config) {},
+		},
+		{
+			name:  "Missing directories and watched paths",
+			files: map[string]string{"composer.lock": "{}"},
+			setup: func(config *Config) {
+				config.DirMigrations = "db/migrations"
+				config.Watch = []string{"composer.lock", "lib", "modules?"}
+				_ = os.Remove(filepath.Join(config.DirSymfonyProject, FrontController))
+			},
+			wantProblems: []string{
+				"dir_migrations: directory db/migrations not found in the project, set dir_migrations (or CACHE_WARMER_DIR_MIGRATIONS) to an existing directory",
+				"watch: lib not found in the project, create it or mark it optional as lib?",
+			},
+		},
+		{
+			name: "Missing console",
+			setup: func(config *Config) {
+				config.SymfonyConsolePath = "bin/symfony"
+			},
+			wantProblems: []string{"console_path: symfony console bin/symfony not found in the project"},
+		},
+		{
+			name:  "Vendor typo",
+			files: map[string]string{"vendor/composer/installed.json": installed, "vendor/symfony/framework-bundle/composer.json": "{}"},
+			setup: func(config *Config) {
+				config.VendorWatch = true
+				config.VendorList = []string{"symfony/framework-bundle", "symfony/framwork-bundle"}
+			},
+			wantProblems: []string{`vendors: package "symfony/framwork-bundle" not found in vendor, did you mean symfony/framework-bundle?`},
+		},
+		{
+			name:  "Exclude patterns",
+			files: map[string]string{"src/Kernel.php": "<?php"},
+			setup: func(config *Config) {
+				config.DirsExclude = append(config.DirsExclude, "src/**/Controller", "!src/Kernel.php", "*.log", "[a-")
+			},
+			wantProblems: []string{`exclude: invalid pattern "[a-": syntax error in pattern`},
+		},
+		{
+			name: "Invalid values",
+			setup: func(config *Config) {
+				config.PoolsProvided = true
+				config.Pools = []string{"--all", "cache app"}
+				config.WarmupPolicy = "later"
+				config.Strategy = "routes"
+				config.Executor = ExecutorCompose
+				config.PathMap = "/app"
+				config.Debounce = -1
+				config.TempFiles = []string{"*.swp", "[.swp"}
+			},
+			wantProblems: []string{
+				"pools: --all clears every pool and cannot be combined with pool names",
+				`pools: invalid pool name "cache app"`,
+				`policy: invalid policy "later", expected queue or restart`,
+				`strategy: invalid strategy "routes", expected dirs or meta`,
+				"container: the compose executor requires a container or service name",
+				`path_map: invalid mapping "/app", expected host:container`,
+				"debounce: must not be negative, got -1ns",
+				`temp_files: invalid pattern "[.swp": syntax error in pattern`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestProject(t, tt.files)
+			tt.setup(&config)
+
+			err := config.Validate()
+			if got := problemsOf(err); !reflect.DeepEqual(got, tt.wantProblems) {
+				t.Errorf("Config.Validate() problems = %q, want %q", got, tt.wantProblems)
+			}
+			if len(tt.wantProblems) == 0 && err != nil {
+				t.Errorf("Config.Validate() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestConfig_Warnings(t *testing.T) {
+	config := newTestProject(t, map[string]string{
+		"src/Kernel.php":                      "<?php",
+		"templates/emails/welcome.mjml":       "",
+		"lib/Tests/LibTest.php":               "<?php",
+		"vendor/acme/foo/Resources/doc/a.rst": "",
+	})
+	config.VendorWatch = true
+	config.VendorList = []string{"acme/foo"}
+	config.Watch = []string{"lib", "modules?"}
+	config.DirsExclude = append(config.DirsExclude, "Controller", "Contoller", "templates/emails/*.mjml", "!src/Kernel.php", "*.log", "[a-", "lib/Tests", "vendor/acme/foo/Resources/doc")
+
+	want := []string{
+		`exclude: "Contoller" matches no path in the watched directories`,
+		`exclude: "*.log" matches no path in the watched directories`,
+	}
+	var got []string
+	for _, warning := range config.Warnings() {
+		got = append(got, warning.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Config.Warnings() = %q, want %q", got, want)
+	}
+	if err := config.Validate(); err == nil || len(problemsOf(err)) != 1 {
+		t.Errorf("Config.Validate() error = %v, want only the invalid pattern", err)
+	}
+}
+
+func TestConfig_ValidateConsole(t *testing.T) {
+	config := newTestProject(t, nil)
+	config.DirSymfonyTemplates = "missing"
+	config.Watch = []string{"lib"}
+	config.DirsExclude = append(config.DirsExclude, "[a-")
+
+	if err := config.ValidateConsole(); err != nil {
+		t.Errorf("Config.ValidateConsole() error = %v, want nil", err)
+	}
+
+	config.WarmupPolicy = "later"
+	if got, want := problemsOf(config.ValidateConsole()), []string{`policy: invalid policy "later", expected queue or restart`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Config.ValidateConsole() problems = %q, want %q", got, want)
+	}
+}
+
+func TestConfig_Validate_MissingProject(t *testing.T) {
+	var config Config
+	config.Init()
+	config.DirSymfonyProject = filepath.Join(t.TempDir(), "missing")
+
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "project directory") {
+		t.Errorf("Config.Validate() error = %v, want a missing project error", err)
+	}
+	if !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("Config.Validate() error = %v, want %v", err, ErrProjectNotFound)
+	}
+}
+
+func TestConfig_Validate_MissingConsole(t *testing.T) {
+	config := newTestProject(t, nil)
+	config.SymfonyConsolePath = "bin/symfony"
+
+	if err := config.Validate(); !errors.Is(err, ErrConsoleNotFound) {
+		t.Errorf("Config.Validate() error = %v, want %v", err, ErrConsoleNotFound)
+	}
+}
+
+func TestInstalledPackages(t *testing.T) {
+	dir := t.TempDir()
+	composer1 := filepath.Join(dir, "installed1.json")
+	composer2 := filepath.Join(dir, "installed2.json")
+	_ = os.WriteFile(composer1, []byte(`[{"name": "acme/foo"}, {"name": "acme/bar"}]`), 0o644)
+	_ = os.WriteFile(composer2, []byte(`{"packages": [{"name": "acme/foo"}], "dev": true}`), 0o644)
+
+	if got, err := InstalledPackages(composer1); err != nil || !reflect.DeepEqual(got, []string{"acme/foo", "acme/bar"}) {
+		t.Errorf("InstalledPackages() Composer 1 = %v, %v", got, err)
+	}
+	if got, err := InstalledPackages(composer2); err != nil || !reflect.DeepEqual(got, []string{"acme/foo"}) {
+		t.Errorf("InstalledPackages() Composer 2 = %v, %v", got, err)
+	}
+	if _, err := InstalledPackages(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("InstalledPackages() on a missing file should fail")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"symfony/framework-bundle", "symfony/twig-bundle", "symfony/console"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "Typo", input: "symfony/framwork-bundle", want: []string{"symfony/framework-bundle"}},
+		{name: "Case", input: "Symfony/Console", want: []string{"symfony/console"}},
+		{name: "No close candidate", input: "doctrine/orm", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Suggest(tt.input, candidates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
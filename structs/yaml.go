@@ -0,0 +1,87 @@
+package structs
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlSetting is a key of a config file along with its value.
+type yamlSetting struct {
+	Key   string
+	Value any // A string or a []string
+}
+
+// parseYAML parses a config file: a mapping whose values are scalars or sequences of scalars. The settings
+// are returned in the order of the file, as setting some keys affects others. Values are returned as a
+// string for scalars, as written in the file, and as a []string for sequences, leaving the conversion to
+// the field types to Config.Set. A key without value, or with a null one, is left out so that it keeps its
+// previous value.
+func parseYAML(data []byte) ([]yamlSetting, error) {
+	var settings []yamlSetting
+	seen := make(map[string]bool)
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return settings, nil // Empty file or comments only
+	}
+
+	mapping := resolveAlias(document.Content[0])
+	if isNull(mapping) {
+		return settings, nil
+	}
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected \"key: value\" settings", mapping.Line)
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], resolveAlias(mapping.Content[i+1])
+
+		key := keyNode.Value
+		if keyNode.Kind != yaml.ScalarNode || key == "" {
+			return nil, fmt.Errorf("line %d: expected a setting name", keyNode.Line)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", keyNode.Line, key)
+		}
+		seen[key] = true
+
+		switch {
+		case isNull(valueNode):
+			continue
+		case valueNode.Kind == yaml.ScalarNode:
+			settings = append(settings, yamlSetting{Key: key, Value: valueNode.Value})
+		case valueNode.Kind == yaml.SequenceNode:
+			items := []string{}
+			for _, itemNode := range valueNode.Content {
+				itemNode = resolveAlias(itemNode)
+				if itemNode.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("line %d: %s: expected a list of single values", itemNode.Line, key)
+				}
+				items = append(items, itemNode.Value)
+			}
+			settings = append(settings, yamlSetting{Key: key, Value: items})
+		default:
+			return nil, fmt.Errorf("line %d: %s: expected a single value or a list, not a mapping", valueNode.Line, key)
+		}
+	}
+
+	return settings, nil
+}
+
+// resolveAlias returns the node an alias, such as *anchor, refers to, or the node itself.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	return node
+}
+
+// isNull reports whether the node is a null scalar, such as an empty value, "~" or "null".
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
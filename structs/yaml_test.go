@@ -0,0 +1,78 @@
+package structs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []yamlSetting
+		wantErr bool
+	}{
+		{
+			name: "Scalars",
+			input: `# Team-wide settings
+env: test
+debug: false  # no --no-debug needed
+container: "php # main"
+path_map: '/home/dev/app:/app'
+`,
+			want: []yamlSetting{{"env", "test"}, {"debug", "false"}, {"container", "php # main"}, {"path_map", "/home/dev/app:/app"}},
+		},
+		{
+			name: "Sequences",
+			input: `---
+pools: [cache.app, "cache.validator"]
+exclude:
+  - var
+  - 'public/build'
+vendors:
+- acme/foo-bundle
+empty: []
+`,
+			want: []yamlSetting{
+				{"pools", []string{"cache.app", "cache.validator"}},
+				{"exclude", []string{"var", "public/build"}},
+				{"vendors", []string{"acme/foo-bundle"}},
+				{"empty", []string{}},
+			},
+		},
+		{
+			name:  "Empty value",
+			input: "container:\nenv: dev\npath_map: ~\n",
+			want:  []yamlSetting{{"env", "dev"}},
+		},
+		{
+			name:  "Plain scalars holding colons",
+			input: "path_map: /home/dev/app:/app\nkey:value: 1\n",
+			want:  []yamlSetting{{"path_map", "/home/dev/app:/app"}, {"key:value", "1"}},
+		},
+		{
+			name:  "Anchors",
+			input: "exclude: &generated [src/Generated]\nwatch: *generated\n",
+			want:  []yamlSetting{{"exclude", []string{"src/Generated"}}, {"watch", []string{"src/Generated"}}},
+		},
+		{name: "Empty file", input: "# Nothing set yet\n"},
+		{name: "Nested mapping", input: "watch:\n  src: true\n", wantErr: true},
+		{name: "Missing colon", input: "env dev\n", wantErr: true},
+		{name: "List of mappings", input: "exclude:\n  - path: var\n", wantErr: true},
+		{name: "Duplicate key", input: "env: dev\nenv: test\n", wantErr: true},
+		{name: "Unterminated string", input: "env: \"dev\n", wantErr: true},
+		{name: "Unterminated sequence", input: "pools: [a, b\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,24 +1,75 @@
 package symfony
 
 import (
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/lettland/cache-warmer/structs"
 )
 
 const (
-	versionOption       = "--version"
-	cacheWarmupArgument = "cache:warmup"
-	cacheClearArgument  = "cache:clear --no-warmup"
-	cachePoolArgument   = "cache:pool:clear"
+	versionOption          = "--version"
+	cacheWarmupCommandName = "cache:warmup"
+	cacheClearCommandName  = "cache:clear"
+	cachePoolCommandName   = "cache:pool:clear"
+	noWarmupOption         = "--no-warmup"
 )
 
+// Command is a Symfony console command. Its name, arguments and options are passed to the console
+// as separate argv elements, so none of them is ever split or interpreted by a shell.
+type Command struct {
+	Name      string   // Command name, empty to only pass options such as --version
+	Arguments []string // Positional arguments
+	Options   []string // Options, including their value if any (--option=value)
+}
+
+// Render returns the argv elements of the command: the name, then the arguments, then the options.
+func (c Command) Render() []string {
+	var argv []string
+	if c.Name != "" {
+		argv = append(argv, c.Name)
+	}
+	argv = append(argv, c.Arguments...)
+
+	return append(argv, c.Options...)
+}
+
+// String returns the command as it would be typed in a terminal.
+func (c Command) String() string {
+	return strings.Join(c.Render(), " ")
+}
+
+// VersionCommand returns the command printing the Symfony version and environment.
+func VersionCommand() Command {
+	return Command{Options: []string{versionOption}}
+}
+
+// CacheClearCommand returns the command clearing the cache without warming it up.
+func CacheClearCommand() Command {
+	return Command{Name: cacheClearCommandName, Options: []string{noWarmupOption}}
+}
+
+// CachePoolClearCommand returns the command clearing the given cache pool. A pool starting with
+// "--", such as --all, is passed as an option instead of a pool name.
+func CachePoolClearCommand(pool string) Command {
+	if strings.HasPrefix(pool, "--") {
+		return Command{Name: cachePoolCommandName, Options: []string{pool}}
+	}
+
+	return Command{Name: cachePoolCommandName, Arguments: []string{pool}}
+}
+
+// CacheWarmupCommand returns the command warming up the cache.
+func CacheWarmupCommand() Command {
+	return Command{Name: cacheWarmupCommandName}
+}
+
 // CheckSymfonyConsole checks if the Symfony console exists at the specified path in the given configuration.
 // If the console does not exist, an error is returned.
 // The function takes a configuration object as a parameter and uses the Symfony project directory and the relative
@@ -34,84 +85,241 @@ func CheckSymfonyConsole(config structs.Config) error {
 	return nil
 }
 
-// RunCommand executes a Symfony console command with the provided configuration and main argument or option.
+// RunCommand executes a Symfony console command with the provided configuration.
 // It returns the combined output of the command and an error, if any.
+// The ctx parameter allows the caller to kill the command before it completes.
 // The config parameter is an instance of the Config struct, which holds the necessary parameters for the application.
-// The mainArgumentOrOption parameter is the main argument or option to be passed to the Symfony console command.
-// The function constructs the command with the appropriate arguments based on the config and executes it using the exec package.
-// If the command fails, it returns an error with a relevant error message.
+// The command parameter is rendered into separate argv elements, followed by the --env and --no-debug options
+// based on the config, and executed using the exec package through the Executor selected by config.Executor.
+// The command runs in its own process group, which is killed as a whole when ctx is canceled or when
+// config.CommandTimeout elapses, so no PHP process is left behind.
+// When config.Verbose is set, the output is also streamed line by line to LiveOutput while the command runs.
+// If the command exits with a non-zero status, times out or is canceled, it returns a *CommandError holding
+// the output captured so far.
+// If the command fails otherwise, it returns an error with a relevant error message.
 // The return value is the output of the command as a string and any error encountered during execution.
-func RunCommand(config structs.Config, mainArgumentOrOption string) (string, error) {
+func RunCommand(ctx context.Context, config structs.Config, command Command) (string, error) {
 	envOption := fmt.Sprintf("--env=%s", config.SymfonyEnv)
-	consoleFullPath := filepath.Join(config.DirSymfonyProject, config.SymfonyConsolePath)
 
-	args := []string{mainArgumentOrOption, envOption}
+	executor, err := NewExecutor(config)
+	if err != nil {
+		return "", err
+	}
+
+	args := append(command.Render(), envOption)
 	if !config.SymfonyDebug {
 		args = append(args, "--no-debug")
 	}
 
-	output, err := exec.Command(consoleFullPath, args...).CombinedOutput()
+	if config.CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.CommandTimeout)
+		defer cancel()
+	}
+
+	cmd := executor.Command(ctx, config, args)
+	setProcessGroup(cmd)
+
+	var output string
+	if config.Verbose {
+		currentStep := getStep(command)
+		stream := NewStreamWriter(LiveOutput, currentStep.label, currentStep.color)
+		cmd.Stdout = stream
+		cmd.Stderr = stream
+		err = cmd.Run()
+		stream.Flush()
+		output = stream.String()
+	} else {
+		var combined []byte
+		combined, err = cmd.CombinedOutput()
+		output = string(combined)
+	}
+
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", &CommandError{Args: args, ExitCode: -1, Output: output, Err: ctx.Err(), Timeout: config.CommandTimeout}
+		}
+
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
-			return "", fmt.Errorf("symfony command failed: %s", exitErr.Error())
+			return "", &CommandError{Args: args, ExitCode: exitErr.ExitCode(), Output: output}
 		}
 
 		return "", fmt.Errorf("failed to execute Symfony command: %w", err)
 	}
 
-	return string(output), nil
+	return output, nil
+}
+
+// CommandError is returned by RunCommand when the Symfony console exits with a non-zero status, or is
+// killed as it timed out or was canceled.
+type CommandError struct {
+	Args     []string      // Arguments passed to the console
+	ExitCode int           // Exit status of the console, -1 when it was killed
+	Output   string        // Combined stdout and stderr of the console
+	Err      error         // Why the console was killed, context.DeadlineExceeded or context.Canceled, nil otherwise
+	Timeout  time.Duration // Maximum duration of the command, reported when it timed out
+}
+
+// Error returns the failed arguments along with the exit status, or why the console was killed.
+func (e *CommandError) Error() string {
+	switch {
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		return fmt.Sprintf("symfony command timed out after %s: %s", e.Timeout, strings.Join(e.Args, " "))
+	case e.Err != nil:
+		return fmt.Sprintf("symfony command canceled: %s: %v", strings.Join(e.Args, " "), e.Err)
+	}
+
+	return fmt.Sprintf("symfony command failed: %s: exit status %d", strings.Join(e.Args, " "), e.ExitCode)
+}
+
+// Unwrap returns why the console was killed, so that errors.Is matches context.Canceled.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Tail returns the last n non-blank lines of the command output, which usually hold the PHP exception
+// message and the file and line it was thrown at.
+func (e *CommandError) Tail(n int) []string {
+	var lines []string
+	for _, line := range strings.Split(e.Output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, " \r"))
+		}
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines
 }
 
 // Version executes a Symfony console command with the provided configuration and the "--version" option.
 // It returns the combined output of the command as a string and any error encountered during execution.
 // The config parameter is an instance of the Config struct, which holds the necessary parameters for the application.
-// The function calls the RunCommand function passing the config and versionOption as arguments.
+// The function calls the RunCommand function passing the ctx, config and the version command as arguments.
 // The return value is the output of the command as a string and any error encountered.
-func Version(config structs.Config) (string, error) {
-	return RunCommand(config, versionOption)
+func Version(ctx context.Context, config structs.Config) (string, error) {
+	return RunCommand(ctx, config, VersionCommand())
+}
+
+// Kinds of steps run by CacheWarmup.
+const (
+	StepClear  = "clear"  // Clear the cache with cache:clear --no-warmup
+	StepRemove = "remove" // Remove the cache directory with rm -rf
+	StepPool   = "pool"   // Clear a cache pool with cache:pool:clear
+	StepWarmup = "warmup" // Warm up the cache with cache:warmup
+)
+
+// stepErrorMessages describes the failure of each kind of step.
+var stepErrorMessages = map[string]string{
+	StepClear:  "failed to clear cache",
+	StepRemove: "failed to remove cache",
+	StepPool:   "failed to clear pool",
+	StepWarmup: "failed to warm up cache",
+}
+
+// WarmupStep is one of the commands run by CacheWarmup.
+type WarmupStep struct {
+	Kind    string // StepClear, StepRemove, StepPool or StepWarmup
+	Command string // Command run by the step, as it would be typed in a terminal
+	run     func(ctx context.Context, config structs.Config) (string, error)
+}
+
+// Run runs the step and returns the output of its command. If the step fails, it returns a *StepError.
+func (s WarmupStep) Run(ctx context.Context, config structs.Config) (string, error) {
+	output, err := s.run(ctx, config)
+	if err != nil {
+		return "", &StepError{Kind: s.Kind, Err: err}
+	}
+
+	return output, nil
+}
+
+// StepError is returned when a warmup step fails. It tells which kind of step failed and wraps its error.
+type StepError struct {
+	Kind string // Kind of the failed step
+	Err  error  // Error returned by the step
 }
 
-// CacheWarmup warms up the cache based on the provided configuration.
-// If the config.ClearCache flag is set to true, it clears the cache using the cache:clear command.
-// If the config.ForceClearCache flag is set to true, it removes the cache directory using the rm -rf command.
-// If the config.PoolsProvided flag is set to true, it clears the specified cache pools using the cache:pool:clear command.
-// Finally, it warms up the cache using the cache:warmup command.
-// The function returns the output of the cache:warmup command as a string and any error encountered during execution.
-func CacheWarmup(config structs.Config) (string, error) {
+// Error describes the failed step along with its error.
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", stepErrorMessages[e.Kind], e.Err)
+}
+
+// Unwrap returns the error of the step, such as a *CommandError.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// WarmupSteps returns the steps run by CacheWarmup for the provided configuration, in order.
+// If the config.ClearCache flag is set to true, the cache is first cleared using the cache:clear command.
+// If the config.ForceClearCache flag is set to true, the cache directory is removed using the rm -rf command.
+// If the config.PoolsProvided flag is set to true, each pool is cleared using the cache:pool:clear command.
+// The last step always warms up the cache using the cache:warmup command.
+func WarmupSteps(config structs.Config) []WarmupStep {
+	var steps []WarmupStep
+
 	if config.ClearCache {
-		_, err := RunCommand(config, cacheClearArgument)
-		if err != nil {
-			return "", fmt.Errorf("failed to clear cache: %w", err)
-		}
+		steps = append(steps, commandStep(StepClear, CacheClearCommand()))
 	}
 
 	if config.ForceClearCache {
-		err := RemoveCache(config)
-		if err != nil {
-			return "", fmt.Errorf("failed to remove cache: %w", err)
-		}
+		steps = append(steps, WarmupStep{
+			Kind:    StepRemove,
+			Command: "rm -rf " + filepath.Join("var", "cache"),
+			run: func(ctx context.Context, config structs.Config) (string, error) {
+				return "", RemoveCache(ctx, config)
+			},
+		})
 	}
 
 	if config.PoolsProvided {
 		for _, pool := range config.Pools {
-			_, err := RunCommand(config, cachePoolArgument+" "+pool)
-			if err != nil {
-				return "", fmt.Errorf("failed to clear pool: %w", err)
-			}
+			steps = append(steps, commandStep(StepPool, CachePoolClearCommand(pool)))
+		}
+	}
+
+	return append(steps, commandStep(StepWarmup, CacheWarmupCommand()))
+}
+
+// commandStep returns a step running the given console command.
+func commandStep(kind string, command Command) WarmupStep {
+	return WarmupStep{
+		Kind:    kind,
+		Command: command.String(),
+		run: func(ctx context.Context, config structs.Config) (string, error) {
+			return RunCommand(ctx, config, command)
+		},
+	}
+}
+
+// CacheWarmup warms up the cache based on the provided configuration, running each of the WarmupSteps in order.
+// Cancelling ctx kills the running command and skips the remaining ones.
+// The function returns the output of the cache:warmup command as a string, or a *StepError for the first
+// step that failed.
+func CacheWarmup(ctx context.Context, config structs.Config) (string, error) {
+	var output string
+
+	for _, step := range WarmupSteps(config) {
+		out, err := step.Run(ctx, config)
+		if err != nil {
+			return "", err
 		}
+		output = out
 	}
 
-	return RunCommand(config, cacheWarmupArgument)
+	return output, nil
 }
 
 // RemoveCache removes the cache directory based on the provided configuration.
 // It takes a Config object as its parameter, which holds the necessary parameters
 // for the application. The cache directory is removed using the "rm -rf" command.
 // If the cache directory is not within the project directory, an error is returned.
-// If the removal of the cache directory fails, an error is returned.
+// If the removal of the cache directory fails or ctx is canceled before it completes, an error is returned.
 // The function returns an error if any error occurs, otherwise it returns nil.
-func RemoveCache(config structs.Config) error {
+func RemoveCache(ctx context.Context, config structs.Config) error {
 	projectDir := config.DirSymfonyProject
 	cacheDir := filepath.Join(projectDir, "var", "cache")
 
@@ -119,8 +327,9 @@ func RemoveCache(config structs.Config) error {
 		return fmt.Errorf("invalid projectDir: %s is not within the root directory", cacheDir)
 	}
 
-	cmd := exec.Command("rm", "-rf", cacheDir)
+	cmd := exec.CommandContext(ctx, "rm", "-rf", cacheDir)
 	cmd.Dir = projectDir
+	setProcessGroup(cmd)
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to remove cache directory: %v", err)
@@ -129,37 +338,6 @@ func RemoveCache(config structs.Config) error {
 	return nil
 }
 
-// GetSymfonyProjectDir returns the path to the Symfony project directory based on the command line arguments.
-// It retrieves the current working directory, parses the command line arguments, and checks for the existence of a provided path.
-// If the provided path is relative, it joins it with the current working directory.
-// If the provided path does not exist, it returns an error.
-// Otherwise, it returns the path to the Symfony project directory and nil error.
-func GetSymfonyProjectDir() (string, error) {
-	execDir, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-
-	flag.Parse()
-	args := flag.Args()
-
-	if len(args) == 0 {
-		return "", fmt.Errorf("no path provided")
-	}
-
-	path := args[0]
-
-	if !filepath.IsAbs(path) {
-		path = filepath.Join(execDir, path)
-	}
-
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return "", err
-	}
-
-	return path, nil
-}
-
 func EnsureDir(dirName string) error {
 	err := os.Mkdir(dirName, os.ModePerm)
 	if err == nil {
@@ -1,11 +1,19 @@
 package symfony
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/lettland/cache-warmer/structs"
 )
@@ -57,53 +65,297 @@ func TestCheckSymfonyConsole(t *testing.T) {
 	}
 }
 
-func TestGetSymfonyProjectDir(t *testing.T) {
-	execDir, _ := os.Getwd()
-	osArgs := os.Args
-	defer func() { os.Args = osArgs }()
+func newFakeConsole(t *testing.T, script string) structs.Config {
+	t.Helper()
 
-	testCases := map[string]struct {
-		args          []string
-		expectedDir   string
-		expectedError string
-		preFunc       func()
+	var config structs.Config
+	config.Init()
+	config.DirSymfonyProject = t.TempDir()
+
+	consolePath := filepath.Join(config.DirSymfonyProject, config.SymfonyConsolePath)
+	if err := os.MkdirAll(filepath.Dir(consolePath), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(consolePath, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestRunCommand_Timeout(t *testing.T) {
+	// The console spawns a child holding the output pipe: it must be killed along with the console.
+	config := newFakeConsole(t, "echo 'Warming up the cache'\nsleep 30 &\nwait")
+	config.CommandTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := RunCommand(context.Background(), config, CacheWarmupCommand())
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("RunCommand() error = %v, want a timeout error", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !reflect.DeepEqual(cmdErr.Tail(1), []string{"Warming up the cache"}) {
+		t.Errorf("RunCommand() error = %#v, want a *CommandError holding the output", err)
+	}
+	if elapsed := time.Since(start); elapsed > processWaitDelay {
+		t.Errorf("RunCommand() returned after %v, the process group was not killed", elapsed)
+	}
+}
+
+func TestRunCommand_Canceled(t *testing.T) {
+	config := newFakeConsole(t, "sleep 30 &\nwait")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := RunCommand(ctx, config, CacheWarmupCommand())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RunCommand() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > processWaitDelay {
+		t.Errorf("RunCommand() returned after %v, the process group was not killed", elapsed)
+	}
+}
+
+func TestRunCommand_ContainerTimeout(t *testing.T) {
+	// The fake docker runs the command in a session of its own, out of reach of the process group
+	// kill, as docker does in the container: only the docker exec killing the pid can stop it.
+	setsid, err := exec.LookPath("setsid")
+	if err != nil {
+		t.Skip("setsid is not available")
+	}
+	binDir := t.TempDir()
+	docker := fmt.Sprintf(`#!/bin/sh
+[ "$1" = compose ] && shift
+shift
+while [ "$1" = -T ] || [ "$1" = -w ]; do [ "$1" = -w ] && shift; shift; done
+shift
+exec %s -w "$@"
+`, setsid)
+	if err := os.WriteFile(filepath.Join(binDir, "docker"), []byte(docker), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	for _, executor := range []string{structs.ExecutorCompose, structs.ExecutorDocker} {
+		t.Run(executor, func(t *testing.T) {
+			config := newFakeConsole(t, "echo $$ > console.pid\nexec sleep 30")
+			config.Executor = executor
+			config.Container = "php"
+			config.CommandTimeout = 200 * time.Millisecond
+
+			start := time.Now()
+			_, err := RunCommand(context.Background(), config, CacheWarmupCommand())
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("RunCommand() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if elapsed := time.Since(start); elapsed > processWaitDelay {
+				t.Errorf("RunCommand() returned after %v, the console was not killed", elapsed)
+			}
+
+			data, err := os.ReadFile(filepath.Join(config.DirSymfonyProject, "console.pid"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			process, err := os.FindProcess(pid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for deadline := time.Now().Add(time.Second); process.Signal(syscall.Signal(0)) == nil; time.Sleep(10 * time.Millisecond) {
+				if time.Now().After(deadline) {
+					_ = process.Kill()
+					t.Fatal("the console is still running in the container")
+				}
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	config := newFakeConsole(t, `echo "Symfony 7.1.5 (env: $2, debug: true)"`)
+
+	out, err := Version(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Symfony 7.1.5 (env: --env=dev, debug: true)\n"; out != want {
+		t.Errorf("Version() = %q, want %q", out, want)
+	}
+}
+
+func TestRunCommand_CommandError(t *testing.T) {
+	config := newFakeConsole(t, `echo "In Kernel.php line 12:"
+echo
+echo "  Invalid service \"App\\Foo\"." >&2
+exit 255`)
+
+	_, err := CacheWarmup(context.Background(), config)
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("CacheWarmup() error = %v, want a *CommandError", err)
+	}
+	if cmdErr.ExitCode != 255 {
+		t.Errorf("CommandError.ExitCode = %d, want 255", cmdErr.ExitCode)
+	}
+	if want := []string{cacheWarmupCommandName, "--env=dev"}; !reflect.DeepEqual(cmdErr.Args, want) {
+		t.Errorf("CommandError.Args = %v, want %v", cmdErr.Args, want)
+	}
+	if want := "symfony command failed: cache:warmup --env=dev: exit status 255"; cmdErr.Error() != want {
+		t.Errorf("CommandError.Error() = %q, want %q", cmdErr.Error(), want)
+	}
+	if want := []string{"In Kernel.php line 12:", `  Invalid service "App\Foo".`}; !reflect.DeepEqual(cmdErr.Tail(5), want) {
+		t.Errorf("CommandError.Tail() = %q, want %q", cmdErr.Tail(5), want)
+	}
+}
+
+func TestCommandError_Tail(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		n      int
+		want   []string
+	}{
+		{name: "Empty output", output: "", n: 3, want: nil},
+		{name: "Shorter than n", output: "a\n\nb\n", n: 3, want: []string{"a", "b"}},
+		{name: "Longer than n", output: "a\nb\nc\nd\r\n", n: 2, want: []string{"c", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &CommandError{Output: tt.output}
+			if got := err.Tail(tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CommandError.Tail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheWarmup_Argv(t *testing.T) {
+	// The fake console logs each argv element on its own line, each invocation ending with "--".
+	tests := []struct {
+		name     string
+		setup    func(config *structs.Config)
+		wantArgv []string
 	}{
-		"ExistingAbsolutePath": {
-			args:        []string{"", "/tmp"},
-			expectedDir: "/tmp",
+		{
+			name:     "Warmup only",
+			setup:    func(config *structs.Config) {},
+			wantArgv: []string{"cache:warmup", "--env=dev", "--"},
 		},
-		"ExistingRelativePath": {
-			args:        []string{"", "symfony"},
-			expectedDir: filepath.Join(execDir, "symfony"),
-			preFunc: func() {
-				err := EnsureDir("symfony")
-				if err != nil {
-					t.Fatal(err)
-				}
+		{
+			name: "Clear cache, no debug",
+			setup: func(config *structs.Config) {
+				config.ClearCache = true
+				config.SymfonyDebug = false
+				config.SymfonyEnv = "test"
+			},
+			wantArgv: []string{
+				"cache:clear", "--no-warmup", "--env=test", "--no-debug", "--",
+				"cache:warmup", "--env=test", "--no-debug", "--",
 			},
 		},
-		"MissingPath": {
-			args:          []string{"", "missingDir"},
-			expectedError: fmt.Sprintf("stat %s: no such file or directory", filepath.Join(execDir, "missingDir")),
-		},
-		"NoArguments": {
-			args:          []string{""},
-			expectedError: "no path provided",
+		{
+			name: "Pools",
+			setup: func(config *structs.Config) {
+				config.PoolsProvided = true
+				config.Pools = []string{"cache.app", "--all"}
+			},
+			wantArgv: []string{
+				"cache:pool:clear", "cache.app", "--env=dev", "--",
+				"cache:pool:clear", "--all", "--env=dev", "--",
+				"cache:warmup", "--env=dev", "--",
+			},
 		},
 	}
 
-	for testName, tc := range testCases {
-		os.Args = tc.args
-		if tc.preFunc != nil {
-			tc.preFunc()
-		}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newFakeConsole(t, `printf '%s\n' "$@" -- >> "$(dirname "$0")/argv.log"`)
+			tt.setup(&config)
+
+			if _, err := CacheWarmup(context.Background(), config); err != nil {
+				t.Fatal(err)
+			}
+
+			log, err := os.ReadFile(filepath.Join(config.DirSymfonyProject, "bin", "argv.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Split(strings.TrimSuffix(string(log), "\n"), "\n"); !reflect.DeepEqual(got, tt.wantArgv) {
+				t.Errorf("console argv = %q, want %q", got, tt.wantArgv)
+			}
+		})
+	}
+}
+
+func TestWarmupSteps(t *testing.T) {
+	var config structs.Config
+	config.Init()
+	config.ClearCache = true
+	config.ForceClearCache = true
+	config.PoolsProvided = true
+	config.Pools = []string{"cache.app"}
+
+	var kinds, commands []string
+	for _, step := range WarmupSteps(config) {
+		kinds = append(kinds, step.Kind)
+		commands = append(commands, step.Command)
+	}
+
+	if want := []string{StepClear, StepRemove, StepPool, StepWarmup}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("WarmupSteps() kinds = %v, want %v", kinds, want)
+	}
+	if want := []string{"cache:clear --no-warmup", "rm -rf var/cache", "cache:pool:clear cache.app", "cache:warmup"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("WarmupSteps() commands = %q, want %q", commands, want)
+	}
+}
+
+func TestCacheWarmup_StepError(t *testing.T) {
+	config := newFakeConsole(t, `[ "$1" = "cache:pool:clear" ] && exit 1
+echo "warmed up"`)
+	config.PoolsProvided = true
+	config.Pools = []string{"cache.app"}
+
+	_, err := CacheWarmup(context.Background(), config)
 
-		result, err := GetSymfonyProjectDir()
-		if err != nil && err.Error() != tc.expectedError {
-			t.Errorf("%s: unexpected error, expected %v, but got: %v", testName, tc.expectedError, err)
-		}
-		if tc.expectedDir != result {
-			t.Errorf("%s: unexpected directory return, expected %v, but got: %v", testName, tc.expectedDir, result)
-		}
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Kind != StepPool {
+		t.Fatalf("CacheWarmup() error = %v, want a *StepError for the pool step", err)
+	}
+	if want := "failed to clear pool: symfony command failed: cache:pool:clear cache.app --env=dev: exit status 1"; err.Error() != want {
+		t.Errorf("CacheWarmup() error = %q, want %q", err.Error(), want)
+	}
+
+	config.Pools = nil
+	if out, err := CacheWarmup(context.Background(), config); err != nil || out != "warmed up\n" {
+		t.Errorf("CacheWarmup() = %q, %v, want the warmup output", out, err)
+	}
+}
+
+func TestCommand_Render(t *testing.T) {
+	tests := []struct {
+		name    string
+		command Command
+		want    []string
+	}{
+		{name: "Version", command: VersionCommand(), want: []string{"--version"}},
+		{name: "Cache clear", command: CacheClearCommand(), want: []string{"cache:clear", "--no-warmup"}},
+		{name: "Named pool", command: CachePoolClearCommand("cache.app"), want: []string{"cache:pool:clear", "cache.app"}},
+		{name: "All pools", command: CachePoolClearCommand("--all"), want: []string{"cache:pool:clear", "--all"}},
+		{name: "Arguments and options", command: Command{Name: "debug:container", Arguments: []string{"router"}, Options: []string{"--show-arguments"}}, want: []string{"debug:container", "router", "--show-arguments"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.command.Render(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Command.Render() = %q, want %q", got, tt.want)
+			}
+		})
 	}
 }
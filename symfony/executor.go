@@ -0,0 +1,194 @@
+package symfony
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+// Executor builds the process running the Symfony console. Executors other than the direct one run the
+// console through another program, such as docker, which must be available on the PATH.
+type Executor interface {
+	// Command returns the process running the console with the given argv.
+	Command(ctx context.Context, config structs.Config, argv []string) *exec.Cmd
+	// String describes how the console is run.
+	String() string
+}
+
+// NewExecutor returns the executor selected by config.Executor.
+func NewExecutor(config structs.Config) (Executor, error) {
+	switch config.Executor {
+	case "", structs.ExecutorDirect:
+		return DirectExecutor{}, nil
+	case structs.ExecutorCompose:
+		if config.Container == "" {
+			return nil, fmt.Errorf("the %s executor requires a service name", config.Executor)
+		}
+		return ComposeExecutor{Service: config.Container}, nil
+	case structs.ExecutorDocker:
+		if config.Container == "" {
+			return nil, fmt.Errorf("the %s executor requires a container name", config.Executor)
+		}
+		return DockerExecutor{Container: config.Container}, nil
+	case structs.ExecutorSymfony:
+		return SymfonyCLIExecutor{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown executor %q, expected %s, %s, %s or %s", config.Executor, structs.ExecutorDirect, structs.ExecutorCompose, structs.ExecutorDocker, structs.ExecutorSymfony)
+}
+
+// DirectExecutor runs the console on the host.
+type DirectExecutor struct{}
+
+// Command runs the console file directly.
+func (DirectExecutor) Command(ctx context.Context, config structs.Config, argv []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, filepath.Join(config.DirSymfonyProject, config.SymfonyConsolePath), argv...)
+	cmd.Dir = config.DirSymfonyProject
+
+	return cmd
+}
+
+func (DirectExecutor) String() string {
+	return structs.ExecutorDirect
+}
+
+// ComposeExecutor runs the console inside a docker compose service, from the project directory mapped into the container.
+type ComposeExecutor struct {
+	Service string
+}
+
+// Command runs docker compose exec from the project directory, so the compose file of the project is used.
+// Canceling ctx also kills the console inside the container, see containerCommand.
+func (e ComposeExecutor) Command(ctx context.Context, config structs.Config, argv []string) *exec.Cmd {
+	containerDir := MapPath(config, config.DirSymfonyProject)
+	execArgs := []string{"compose", "exec", "-T", "-w", containerDir, e.Service}
+	stopArgs := []string{"compose", "exec", "-T", e.Service}
+
+	return containerCommand(ctx, config, execArgs, stopArgs, argv)
+}
+
+func (e ComposeExecutor) String() string {
+	return "docker compose exec " + e.Service
+}
+
+// DockerExecutor runs the console inside a running container, from the project directory mapped into the container.
+type DockerExecutor struct {
+	Container string
+}
+
+// Command runs docker exec on the container. Canceling ctx also kills the console inside the container,
+// see containerCommand.
+func (e DockerExecutor) Command(ctx context.Context, config structs.Config, argv []string) *exec.Cmd {
+	containerDir := MapPath(config, config.DirSymfonyProject)
+
+	return containerCommand(ctx, config, []string{"exec", "-w", containerDir, e.Container}, []string{"exec", e.Container}, argv)
+}
+
+func (e DockerExecutor) String() string {
+	return "docker exec " + e.Container
+}
+
+// containerPidScript runs the console in the background to record its pid in the file given as $0, then
+// waits for it and removes the file. The exit status of the console is kept.
+const containerPidScript = `"$@" & pid=$!; echo "$pid" > "$0"; wait "$pid"; status=$?; rm -f "$0"; exit "$status"`
+
+// containerKillScript kills the console whose pid was recorded in the file given as $0, if it still runs.
+const containerKillScript = `[ -f "$0" ] && kill -KILL "$(cat "$0")"`
+
+// containerStopTimeout bounds the docker command killing the console inside the container.
+const containerStopTimeout = 5 * time.Second
+
+// containerCommand returns the docker command running the console inside a container: execArgs is the
+// docker command line up to the container, stopArgs the one used to run the kill command in it.
+// Killing the local docker client on cancellation leaves the console running in the container, so the
+// console runs under a shell recording its pid in a file of the container, and the cancellation first
+// kills that pid with another docker exec. This requires sh in the image, which every PHP image has.
+func containerCommand(ctx context.Context, config structs.Config, execArgs, stopArgs, argv []string) *exec.Cmd {
+	containerDir := MapPath(config, config.DirSymfonyProject)
+	pidFile := containerPidFile()
+
+	args := append(execArgs, "sh", "-c", containerPidScript, pidFile, path.Join(containerDir, filepath.ToSlash(config.SymfonyConsolePath)))
+	cmd := exec.CommandContext(ctx, "docker", append(args, argv...)...)
+	cmd.Dir = config.DirSymfonyProject
+	cmd.Cancel = func() error {
+		stopCtx, cancel := context.WithTimeout(context.Background(), containerStopTimeout)
+		defer cancel()
+
+		stop := exec.CommandContext(stopCtx, "docker", append(stopArgs, "sh", "-c", containerKillScript, pidFile)...)
+		stop.Dir = config.DirSymfonyProject
+		stopErr := stop.Run()
+
+		if err := cmd.Process.Kill(); err != nil {
+			return err
+		}
+		return stopErr
+	}
+
+	return cmd
+}
+
+// containerPidFile returns a path in the container, unique to one run of the console, to record its pid.
+func containerPidFile() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return "/tmp/cache-warmer-" + hex.EncodeToString(id) + ".pid"
+}
+
+// SymfonyCLIExecutor runs the console through the Symfony CLI, which picks the PHP version configured for the project.
+type SymfonyCLIExecutor struct{}
+
+// Command runs symfony console from the project directory.
+func (SymfonyCLIExecutor) Command(ctx context.Context, config structs.Config, argv []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "symfony", append([]string{"console"}, argv...)...)
+	cmd.Dir = config.DirSymfonyProject
+
+	return cmd
+}
+
+func (SymfonyCLIExecutor) String() string {
+	return "symfony console"
+}
+
+// MapPath translates a host path into the path it has inside the container, using the
+// config.PathMap "host:container" mapping. Paths outside the mapped host directory, or
+// any path when no mapping is configured, are returned unchanged.
+func MapPath(config structs.Config, hostPath string) string {
+	hostDir, containerDir, found := strings.Cut(config.PathMap, ":")
+	if !found || hostDir == "" || containerDir == "" {
+		return filepath.ToSlash(hostPath)
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(hostDir), hostPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(hostPath)
+	}
+
+	return path.Join(containerDir, filepath.ToSlash(rel))
+}
+
+// UnmapPath translates a path seen inside the container, such as a path read from the Symfony cache,
+// into the host path, reversing MapPath. Paths outside the mapped container directory, or any path
+// when no mapping is configured, are returned unchanged.
+func UnmapPath(config structs.Config, containerPath string) string {
+	hostDir, containerDir, found := strings.Cut(config.PathMap, ":")
+	if !found || hostDir == "" || containerDir == "" {
+		return filepath.FromSlash(containerPath)
+	}
+
+	containerDir = path.Clean(containerDir)
+	containerPath = path.Clean(containerPath)
+	if containerPath != containerDir && !strings.HasPrefix(containerPath, strings.TrimSuffix(containerDir, "/")+"/") {
+		return filepath.FromSlash(containerPath)
+	}
+
+	return filepath.Join(filepath.Clean(hostDir), filepath.FromSlash(strings.TrimPrefix(containerPath, containerDir)))
+}
@@ -0,0 +1,142 @@
+package symfony
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+func TestNewExecutor(t *testing.T) {
+	tests := []struct {
+		name      string
+		executor  string
+		container string
+		want      Executor
+		wantErr   bool
+	}{
+		{name: "Default", executor: "", want: DirectExecutor{}},
+		{name: "Direct", executor: structs.ExecutorDirect, want: DirectExecutor{}},
+		{name: "Compose", executor: structs.ExecutorCompose, container: "php", want: ComposeExecutor{Service: "php"}},
+		{name: "Compose without service", executor: structs.ExecutorCompose, wantErr: true},
+		{name: "Docker", executor: structs.ExecutorDocker, container: "app-php-1", want: DockerExecutor{Container: "app-php-1"}},
+		{name: "Docker without container", executor: structs.ExecutorDocker, wantErr: true},
+		{name: "Symfony CLI", executor: structs.ExecutorSymfony, want: SymfonyCLIExecutor{}},
+		{name: "Unknown", executor: "podman", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewExecutor(structs.Config{Executor: tt.executor, Container: tt.container})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewExecutor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewExecutor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutor_Command(t *testing.T) {
+	config := structs.Config{
+		DirSymfonyProject:  "/home/dev/app",
+		SymfonyConsolePath: "bin/console",
+		PathMap:            "/home/dev/app:/var/www/html",
+	}
+	argv := []string{"cache:warmup", "--env=dev"}
+
+	tests := []struct {
+		name     string
+		executor Executor
+		wantArgs []string
+	}{
+		{
+			name:     "Direct",
+			executor: DirectExecutor{},
+			wantArgs: []string{"/home/dev/app/bin/console", "cache:warmup", "--env=dev"},
+		},
+		{
+			name:     "Compose",
+			executor: ComposeExecutor{Service: "php"},
+			wantArgs: []string{"docker", "compose", "exec", "-T", "-w", "/var/www/html", "php", "sh", "-c", containerPidScript, "", "/var/www/html/bin/console", "cache:warmup", "--env=dev"},
+		},
+		{
+			name:     "Docker",
+			executor: DockerExecutor{Container: "app-php-1"},
+			wantArgs: []string{"docker", "exec", "-w", "/var/www/html", "app-php-1", "sh", "-c", containerPidScript, "", "/var/www/html/bin/console", "cache:warmup", "--env=dev"},
+		},
+		{
+			name:     "Symfony CLI",
+			executor: SymfonyCLIExecutor{},
+			wantArgs: []string{"symfony", "console", "cache:warmup", "--env=dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.executor.Command(context.Background(), config, argv)
+			if i := slices.Index(cmd.Args, containerPidScript); i >= 0 {
+				if !strings.HasPrefix(cmd.Args[i+1], "/tmp/cache-warmer-") {
+					t.Errorf("Executor.Command() pid file = %v, want a file in /tmp", cmd.Args[i+1])
+				}
+				cmd.Args[i+1] = "" // Unique to each command
+			}
+			if !reflect.DeepEqual(cmd.Args, tt.wantArgs) {
+				t.Errorf("Executor.Command() args = %q, want %q", cmd.Args, tt.wantArgs)
+			}
+			if cmd.Dir != config.DirSymfonyProject {
+				t.Errorf("Executor.Command() dir = %v, want %v", cmd.Dir, config.DirSymfonyProject)
+			}
+		})
+	}
+}
+
+func TestMapPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		pathMap  string
+		hostPath string
+		want     string
+	}{
+		{name: "No mapping", pathMap: "", hostPath: "/home/dev/app", want: "/home/dev/app"},
+		{name: "Mapped root", pathMap: "/home/dev/app:/app", hostPath: "/home/dev/app", want: "/app"},
+		{name: "Mapped subdirectory", pathMap: "/home/dev/app/:/app", hostPath: "/home/dev/app/public/index.php", want: "/app/public/index.php"},
+		{name: "Outside the mapping", pathMap: "/home/dev/app:/app", hostPath: "/home/dev/application", want: "/home/dev/application"},
+		{name: "Invalid mapping", pathMap: "/home/dev/app", hostPath: "/home/dev/app", want: "/home/dev/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MapPath(structs.Config{PathMap: tt.pathMap}, tt.hostPath); got != tt.want {
+				t.Errorf("MapPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmapPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		pathMap       string
+		containerPath string
+		want          string
+	}{
+		{name: "No mapping", pathMap: "", containerPath: "/app/config/services.yaml", want: "/app/config/services.yaml"},
+		{name: "Mapped root", pathMap: "/home/dev/app:/app", containerPath: "/app", want: "/home/dev/app"},
+		{name: "Mapped subdirectory", pathMap: "/home/dev/app:/app/", containerPath: "/app/config/services.yaml", want: "/home/dev/app/config/services.yaml"},
+		{name: "Outside the mapping", pathMap: "/home/dev/app:/app", containerPath: "/application/index.php", want: "/application/index.php"},
+		{name: "Mapped to the container root", pathMap: "/home/dev/app:/", containerPath: "/srv/index.php", want: "/home/dev/app/srv/index.php"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnmapPath(structs.Config{PathMap: tt.pathMap}, tt.containerPath); got != tt.want {
+				t.Errorf("UnmapPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
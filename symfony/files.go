@@ -1,105 +1,239 @@
 package symfony
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/lettland/cache-warmer/ignore"
 	"github.com/lettland/cache-warmer/structs"
 )
 
 // FindFiles searches for files in the specified root directory and its subdirectories.
-// It excludes the specified directories and handles vendor directories based on the vendorWatch flag.
+// It skips the files and directories matched by the exclude patterns and handles vendor directories based on
+// the vendorWatch flag.
 // It returns a list of file paths and an error if any occurred.
-func FindFiles(config structs.Config, root string, excludedDirs []string, vendorWatch bool, vendorList []string) ([]string, error) {
+func FindFiles(config structs.Config, root string, excludes []string, vendorWatch bool, vendorList []string) ([]string, error) {
 	var files []string
 
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
+	err := walkWatched(config, root, excludes, vendorWatch, vendorList, func(path string, d fs.DirEntry) {
+		// Add the file if it's not excluded (like .gitignore)
+		if !d.IsDir() && !strings.HasSuffix(path, ".gitignore") {
+			files = append(files, path)
+		}
+	})
+
+	return files, err
+}
+
+// FindDirs searches for directories in the specified root directory and its subdirectories.
+// It applies the same exclusion and vendor rules as FindFiles, so the returned directories are
+// exactly the ones containing the files FindFiles would return. The root itself is included.
+func FindDirs(config structs.Config, root string, excludes []string, vendorWatch bool, vendorList []string) ([]string, error) {
+	var dirs []string
+
+	err := walkWatched(config, root, excludes, vendorWatch, vendorList, func(path string, d fs.DirEntry) {
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		}
+	})
+
+	return dirs, err
+}
+
+// walkWatched walks the root directory, skipping excluded and unwatched vendor directories,
+// and calls visit for every directory and file that is kept.
+// The exclude patterns are gitignore-style patterns matched against the path relative to the
+// project directory, so that the location of the project itself never excludes anything. The files
+// named like the editor temporary files of config.TempFiles are skipped as well. When
+// config.Gitignore is set, the paths ignored by the .gitignore files of the project and
+// .git/info/exclude are excluded too, unless a configured pattern re-adds them, the root itself
+// being kept even when ignored, as with a vendor package. An excluded directory is still walked
+// when a pattern re-adds a path inside it, without being visited. A vendor directory at the top of
+// the root, holding the dependencies of a package, is never walked. A root linked elsewhere, such
+// as a vendor package installed from a path repository, is walked through its target, the paths
+// being reported below the link.
+func walkWatched(config structs.Config, root string, excludes []string, vendorWatch bool, vendorList []string, visit func(path string, d fs.DirEntry)) error {
+	matcher, err := ignore.New(excludes)
+	if err != nil {
+		return err
+	}
+	tempFiles, err := ignore.New(config.TempFiles)
+	if err != nil {
+		return err
+	}
+	if config.Gitignore {
+		if err = addParentGitignores(matcher, config, root); err != nil {
 			return err
 		}
+		// The root was asked for, so only the paths inside it are ignored, not the root itself
+		rootRel, _ := filepath.Rel(config.DirSymfonyProject, root)
+		matcher.SetGitignoreRoot(rootRel)
+	}
+
+	vendorDir := projectPath(config, config.DirSymfonyVendor)
+	nestedVendorDir := filepath.Join(root, config.DirSymfonyVendor)
 
-		// Skip excluded directories (not individual files)
-		for _, excludedDir := range excludedDirs {
-			if d.IsDir() && strings.Contains(path, excludedDir) {
-				return filepath.SkipDir
+	walkRoot := root
+	if info, err := os.Lstat(root); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if walkRoot, err = filepath.EvalSymlinks(root); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(walkRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A directory removed while being walked is skipped like a deleted one
+			if path != walkRoot && errors.Is(err, fs.ErrNotExist) {
+				return nil
 			}
+			return err
+		}
+		if walkRoot != root {
+			rel, _ := filepath.Rel(walkRoot, path)
+			path = filepath.Join(root, rel)
 		}
 
-		// Handle the vendor directory based on vendorWatch
-		if d.IsDir() {
-			if strings.HasPrefix(path, filepath.Join(root, config.DirSymfonyVendor)) {
-				if !vendorWatch {
-					// If vendorWatch is false, skip the entire vendor directory
-					return filepath.SkipDir
-				}
-
-				// If vendorWatch is true, check if the current path matches any of the vendorList entries
-				if len(vendorList) > 0 {
-					insideVendor := false
-					for _, vendor := range vendorList {
-						if strings.HasPrefix(path, filepath.Join(root, config.DirSymfonyVendor, vendor)) {
-							insideVendor = true
-							break
-						}
-					}
-					if !insideVendor {
-						return filepath.SkipDir
-					}
-				}
+		rel, _ := filepath.Rel(config.DirSymfonyProject, path)
+		if !d.IsDir() {
+			if !matcher.Excluded(rel, false) && !tempFiles.Excluded(d.Name(), false) {
+				visit(path, d)
 			}
-		} else {
-			// Add the file if it's not excluded (like .gitignore)
-			if !strings.HasSuffix(path, ".gitignore") {
-				files = append(files, path)
+			return nil
+		}
+
+		if path == nestedVendorDir {
+			return filepath.SkipDir
+		}
+
+		// Handle the vendor directory based on vendorWatch and vendorList
+		if isWithin(path, vendorDir) && !isWatchedVendorDir(path, vendorDir, vendorWatch, vendorList) {
+			return filepath.SkipDir
+		}
+
+		excluded := matcher.Excluded(rel, true)
+		if excluded && !matcher.Descend(rel) {
+			return filepath.SkipDir
+		}
+
+		// The .gitignore file of a directory applies to its content, not to the directory itself
+		if config.Gitignore {
+			if err = matcher.AddGitignore(filepath.Join(path, ".gitignore"), rel); err != nil {
+				return err
 			}
 		}
 
+		if !excluded {
+			visit(path, d)
+		}
+
 		return nil
 	})
+}
 
-	return files, err
+// addParentGitignores adds the .git/info/exclude file of the project and the .gitignore files of the
+// directories from the project directory down to the parent of the root, the ones of the root and its
+// subdirectories being added while walking it.
+func addParentGitignores(matcher *ignore.Matcher, config structs.Config, root string) error {
+	if err := matcher.AddGitignore(filepath.Join(config.DirSymfonyProject, ".git", "info", "exclude"), ""); err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(config.DirSymfonyProject, root)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+
+	dirs := []string{""}
+	if parent := filepath.Dir(rel); parent != "." {
+		components := strings.Split(parent, string(filepath.Separator))
+		for i := range components {
+			dirs = append(dirs, filepath.Join(components[:i+1]...))
+		}
+	}
+
+	for _, dir := range dirs {
+		if err = matcher.AddGitignore(filepath.Join(config.DirSymfonyProject, dir, ".gitignore"), dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isWatchedVendorDir reports whether a directory of the vendor directory is watched: every one of them
+// when vendorWatch is set without vendorList, otherwise only the listed packages and their parents.
+func isWatchedVendorDir(path, vendorDir string, vendorWatch bool, vendorList []string) bool {
+	if !vendorWatch {
+		return false
+	}
+	if len(vendorList) == 0 {
+		return true
+	}
+
+	for _, vendor := range vendorList {
+		packageDir := filepath.Join(vendorDir, vendor)
+		if isWithin(path, packageDir) || isWithin(packageDir, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWithin reports whether path is dir or one of its descendants. Unlike a plain prefix check,
+// vendor/acme/foobar is not within vendor/acme/foo.
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// projectPath returns the path joined to the project directory, unless it is absolute.
+func projectPath(config structs.Config, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(config.DirSymfonyProject, path)
 }
 
-// GetWatchMap returns a map containing the files to watch and their corresponding last modified timestamps.
-// It takes a `config` parameter of type `structs.Config` which holds the configuration values for the application.
-// It calls the `GetFilesToWatch` function to retrieve the files to watch.
-// For each file, it retrieves the file's stats using `os.Stat`, and adds the file path and last modified timestamp
-// to the `watchMap`. If any error occurs during the process, it returns the error.
-// It returns the `watchMap` containing the files to watch and their corresponding timestamps and nil error on success.
-// Otherwise, it returns nil map and the encountered error.
-// The `watchMap` can be used to compare with the existing files being watched to detect any changes.
+// GetWatchMap returns a snapshot containing the files to watch and their corresponding states.
+// It takes a `config` parameter of type `structs.Config` which holds the configuration values for the application,
+// and the `previous` snapshot, which may be nil, whose content hashes are reused for files that did not move.
+// It calls the `GetFilesToWatch` function to retrieve the files to watch, then `SnapshotFiles` to retrieve the
+// state of each file, which holds its size and last modified timestamp, plus its content hash when
+// `config.HashContent` is enabled.
+// A file removed between the directory walk and its stat, as editors saving atomically do, is left out of the
+// snapshot like a deleted file, and the scan goes on. A file whose state can't be read, such as a permission
+// denied, keeps its previous state and the scan goes on as well: the snapshot is returned along with a
+// *ScanError to report as a warning. Any other error, such as a failed directory walk, fails the scan: it
+// returns a nil snapshot and the encountered error, so that the caller never compares a partial snapshot.
+// The snapshot can be used to compare with the existing files being watched to detect any changes.
 //
 // Note that `GetWatchMap` does not handle removing files from the `watchMap` when they are no longer being watched.
 // This responsibility falls on the caller of this function.
-func GetWatchMap(config structs.Config) (map[string]string, error) {
-	watchMap := make(map[string]string)
+func GetWatchMap(config structs.Config, previous Snapshot) (Snapshot, error) {
 	filesToWatch, err := GetFilesToWatch(config)
-
 	if err != nil {
 		return nil, err
 	}
 
-	for _, file := range filesToWatch {
-		stats, err := os.Stat(file)
-		if err != nil {
-			return nil, fmt.Errorf("can't get stats for the \"%s\" file, check the project permissions or if a new file was created: %v", file, err)
-		}
-		watchMap[file] = stats.ModTime().String()
-	}
-
-	return watchMap, nil
+	return SnapshotFiles(filesToWatch, previous, config.HashContent)
 }
 
+// GetFilesToWatch returns the list of files whose changes should trigger a cache warmup:
+// the .env* files, public/index.php if it exists, the extra files of the watch setting and every file found
+// in the watched Symfony, vendor and extra directories. With the meta strategy, the files and directories
+// listed by the .meta files of the Symfony cache replace the Symfony and vendor directories.
 func GetFilesToWatch(config structs.Config) ([]string, error) {
 	var filesToWatch []string
 
-	// Set up excluded directories
-	var excludedDirs = config.DirsExclude
-	if !config.VendorWatch {
-		excludedDirs = append(excludedDirs, config.DirSymfonyVendor)
+	set, err := getWatchSet(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// Include general files like .env*
@@ -107,45 +241,207 @@ func GetFilesToWatch(config structs.Config) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	filesToWatch = append(filesToWatch, envFiles...)
-
-	indexFile, err := GetSingleFileFromPath(config, "public/index.php")
+	matcher, err := ignore.New(append(append([]string{}, config.TempFiles...), set.excludes...))
 	if err != nil {
 		return nil, err
 	}
-	filesToWatch = append(filesToWatch, indexFile)
-
-	// Directories to watch
-	symfonyDirs := map[string]string{
-		config.DirSymfonyConfig:       config.DirSymfonyConfig,
-		config.DirSymfonySrc:          config.DirSymfonySrc,
-		config.DirSymfonyTemplates:    config.DirSymfonyTemplates,
-		config.DirSymfonyTranslations: config.DirSymfonyTranslations,
-		config.DirMigrations:          config.DirMigrations,
+	// The .env files are read by Symfony whether git ignores them or not, like .env.local
+	for _, file := range envFiles {
+		if !matcher.Excluded(filepath.Base(file), false) {
+			filesToWatch = append(filesToWatch, file)
+		}
 	}
 
+	filesToWatch = append(filesToWatch, set.files...)
+
 	// Watch all files in the specified directories, regardless of their extensions
-	for dir := range symfonyDirs {
-		files, err := FindFiles(config, dir, excludedDirs, false, config.VendorList)
+	for _, root := range set.roots {
+		files, err := FindFiles(config, root.path, set.excludes, root.vendor, set.vendorList)
 		if err != nil {
 			return nil, err
 		}
 		filesToWatch = append(filesToWatch, files...)
 	}
 
+	return filesToWatch, nil
+}
+
+// GetDirsToWatch returns the directories containing the files returned by GetFilesToWatch.
+// The value tells whether the directory is watched recursively: directories created inside a
+// recursive directory must be watched as well, while the project root and the directories of
+// single files are only watched for the few files they hold. The directory of a missing optional
+// path is watched as well when it exists, so that creating the path is noticed.
+func GetDirsToWatch(config structs.Config) (map[string]bool, error) {
+	set, err := getWatchSet(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dirsToWatch := make(map[string]bool)
+	for _, dir := range set.dirs {
+		dirsToWatch[dir] = false
+	}
+
+	for _, root := range set.roots {
+		dirs, err := FindDirs(config, root.path, set.excludes, root.vendor, set.vendorList)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			dirsToWatch[dir] = true
+		}
+	}
+
+	return dirsToWatch, nil
+}
+
+// watchRoot is a directory walked recursively when building the watch set.
+type watchRoot struct {
+	path   string
+	vendor bool // Whether the root is a vendor package directory
+}
+
+// watchSet holds the paths the watch set is built from, all of them resolved against the project
+// directory rather than the working directory.
+type watchSet struct {
+	roots      []watchRoot     // Directories walked recursively
+	files      []string        // Single files outside the roots
+	dirs       []string        // Directories watched for the single files they may hold, not recursively
+	excludes   []string        // Exclude patterns applied when walking the roots
+	vendorList []string        // Vendor packages walked in the vendor roots, all of them when empty
+	added      map[string]bool // Paths already added, by kind, as the meta strategy lists thousands of them
+}
+
+// add reports whether the path was not added yet with this kind, and marks it as added.
+func (set *watchSet) add(kind, path string) bool {
+	if set.added == nil {
+		set.added = make(map[string]bool)
+	}
+	key := kind + ":" + path
+	if set.added[key] {
+		return false
+	}
+	set.added[key] = true
+
+	return true
+}
+
+// addRoot adds a directory walked recursively, unless already added.
+func (set *watchSet) addRoot(path string, vendor bool) {
+	if set.add(fmt.Sprintf("root-%t", vendor), path) {
+		set.roots = append(set.roots, watchRoot{path: path, vendor: vendor})
+	}
+}
+
+// addDir adds a directory watched for the single files it may hold, unless already added.
+func (set *watchSet) addDir(dir string) {
+	if set.add("dir", dir) {
+		set.dirs = append(set.dirs, dir)
+	}
+}
+
+// addFile adds a single file, along with its directory. A missing file is skipped, only its directory
+// being watched, when it exists, so that creating the file is noticed.
+func (set *watchSet) addFile(path string) {
+	if !set.add("file", path) {
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		set.files = append(set.files, path)
+	}
+
+	dir := filepath.Dir(path)
+	if set.added["dir:"+dir] {
+		return
+	}
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		set.addDir(dir)
+	}
+}
+
+// addPaths adds the directories as roots and the files as single files. A missing optional path is
+// skipped, while a missing required one is an error.
+func (set *watchSet) addPaths(config structs.Config, paths []structs.WatchPath) error {
+	for _, watchPath := range paths {
+		path := projectPath(config, watchPath.Path)
+
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err) && watchPath.Optional:
+			set.addFile(path)
+		case os.IsNotExist(err):
+			return fmt.Errorf("watched path %s not found, create it or mark it optional as %s?", path, watchPath.Path)
+		case err != nil:
+			return err
+		case info.IsDir():
+			set.addRoot(path, false)
+		default:
+			set.addFile(path)
+		}
+	}
+
+	return nil
+}
+
+// removeRootFiles removes the single files inside a root, which are already found by walking it.
+func (set *watchSet) removeRootFiles() {
+	set.files = slices.DeleteFunc(set.files, func(file string) bool {
+		return slices.ContainsFunc(set.roots, func(root watchRoot) bool { return isWithin(file, root.path) })
+	})
+}
+
+// getWatchSet returns the watch set of the configured strategy. The meta strategy falls back to the
+// directories until the cache is warmed up, as the .meta files are written by the warmup.
+func getWatchSet(config structs.Config) (watchSet, error) {
+	if config.Strategy == structs.StrategyMeta {
+		if metaFiles := GetMetaFiles(config); len(metaFiles) > 0 {
+			return getMetaWatchSet(config, metaFiles)
+		}
+	}
+
+	return getDirsWatchSet(config)
+}
+
+// getDirsWatchSet returns the Symfony directories to watch followed by the watched vendor packages, if any,
+// and the extra directories of the watch setting, along with the front controller and the extra files.
+// A missing optional path, such as the front controller, is skipped, while a missing required one is an error.
+func getDirsWatchSet(config structs.Config) (watchSet, error) {
+	set := watchSet{excludes: getExcludePatterns(config), vendorList: config.VendorList}
+
+	// Directories to watch
+	for _, dir := range []string{config.DirSymfonyConfig, config.DirSymfonySrc, config.DirSymfonyTemplates, config.DirSymfonyTranslations, config.DirMigrations} {
+		set.addRoot(projectPath(config, dir), false)
+	}
+
 	// If VendorWatch is enabled, watch specific vendor directories
 	if config.VendorWatch {
 		for _, vendor := range config.VendorList {
-			vendorPath := filepath.Join(config.DirSymfonyVendor, vendor)
-			vendorFiles, err := FindFiles(config, vendorPath, excludedDirs, true, config.VendorList)
-			if err != nil {
-				return nil, err
-			}
-			filesToWatch = append(filesToWatch, vendorFiles...)
+			set.addRoot(projectPath(config, filepath.Join(config.DirSymfonyVendor, vendor)), true)
 		}
 	}
 
-	return filesToWatch, nil
+	// The project root holds the .env files
+	set.addDir(config.DirSymfonyProject)
+
+	frontController := structs.WatchPath{Path: structs.FrontController, Optional: true}
+	if err := set.addPaths(config, append([]structs.WatchPath{frontController}, config.WatchPaths()...)); err != nil {
+		return set, err
+	}
+	set.removeRootFiles()
+
+	return set, nil
+}
+
+// getExcludePatterns returns the configured exclude patterns, preceded by the vendor directory when vendors
+// are not watched, so that a configured pattern can still re-add a path inside it. The vendor pattern is
+// anchored to the project directory, so that a directory of the same name deeper in the project is kept.
+func getExcludePatterns(config structs.Config) []string {
+	var excludes []string
+	if !config.VendorWatch {
+		excludes = append(excludes, "/"+config.DirSymfonyVendor+"/")
+	}
+
+	return append(excludes, config.DirsExclude...)
 }
 
 // GetFilesFromPath retrieves a list of files from the specified path based on the provided configuration.
@@ -161,17 +457,3 @@ func GetFilesFromPath(config structs.Config, glob string) ([]string, error) {
 
 	return files, nil
 }
-
-func GetSingleFileFromPath(config structs.Config, filePath string) (string, error) {
-	fullPath := filepath.Join(config.DirSymfonyProject, filePath)
-
-	// Check if the file exists
-	if _, err := os.Stat(fullPath); err != nil {
-		if os.IsNotExist(err) {
-			return "", fmt.Errorf("file not found: %s", fullPath)
-		}
-		return "", err
-	}
-
-	return fullPath, nil
-}
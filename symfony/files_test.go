@@ -0,0 +1,413 @@
+package symfony
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+// newTestProject creates a Symfony project holding the given files, below a directory whose name
+// contains the default excluded directories, and moves the working directory out of it.
+func newTestProject(t *testing.T, files []string) structs.Config {
+	t.Helper()
+
+	var config structs.Config
+	config.Init()
+	config.DirSymfonyProject = filepath.Join(t.TempDir(), "node_modules.git", "project")
+
+	for _, dir := range []string{"config", "src", "templates", "translations", "migrations"} {
+		if err := os.MkdirAll(filepath.Join(config.DirSymfonyProject, dir), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range append([]string{"public/index.php", ".env"}, files...) {
+		path := filepath.Join(config.DirSymfonyProject, file)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The working directory holds directories named like the watched ones, which must not be scanned.
+	workDir := t.TempDir()
+	for _, dir := range []string{"config", "src", "templates", "translations", "migrations", "vendor/acme/foo"} {
+		if err := os.MkdirAll(filepath.Join(workDir, dir), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, dir, "decoy.php"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	execDir, _ := os.Getwd()
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(execDir) })
+
+	return config
+}
+
+// relativeFiles returns the files relative to the project directory, sorted.
+func relativeFiles(t *testing.T, config structs.Config, files []string) []string {
+	t.Helper()
+
+	var rel []string
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			t.Errorf("file %s is not absolute", file)
+		}
+		r, err := filepath.Rel(config.DirSymfonyProject, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	slices.Sort(rel)
+
+	return rel
+}
+
+func TestGetFilesToWatch_OtherWorkingDirectory(t *testing.T) {
+	config := newTestProject(t, []string{
+		"config/services.yaml",
+		"src/Kernel.php",
+		"src/Controller/HomeController.php",
+		"templates/base.html.twig",
+		"translations/messages.en.yaml",
+		"migrations/Version1.php",
+		"vendor/acme/foo/Bundle.php",
+	})
+
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		".env",
+		"config/services.yaml",
+		"migrations/Version1.php",
+		"public/index.php",
+		"src/Controller/HomeController.php",
+		"src/Kernel.php",
+		"templates/base.html.twig",
+		"translations/messages.en.yaml",
+	}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+}
+
+func TestGetFilesToWatch_Vendors(t *testing.T) {
+	config := newTestProject(t, []string{
+		"src/Kernel.php",
+		"vendor/acme/foo/Bundle.php",
+		"vendor/acme/foo/vendor/dev/Tool.php",
+		"vendor/acme/foobar/Bundle.php",
+		"vendor/other/bar/Bundle.php",
+	})
+	config.VendorWatch = true
+	config.VendorList = []string{"acme/foo"}
+
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{".env", "public/index.php", "src/Kernel.php", "vendor/acme/foo/Bundle.php"}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+}
+
+func TestGetFilesToWatch_NestedVendorDirectory(t *testing.T) {
+	// Only the vendor directory of the project is excluded, not a directory of the same name deeper in the sources
+	config := newTestProject(t, []string{
+		"src/Kernel.php",
+		"src/Payment/vendor/Gateway.php",
+		"vendor/acme/foo/Bundle.php",
+	})
+
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{".env", "public/index.php", "src/Kernel.php", "src/Payment/vendor/Gateway.php"}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+}
+
+func TestGetFilesToWatch_PathRepositoryVendor(t *testing.T) {
+	config := newTestProject(t, []string{"src/Kernel.php", "packages/foo/src/Bundle.php"})
+	config.VendorWatch = true
+	config.VendorList = []string{"acme/foo"}
+
+	link := filepath.Join(config.DirSymfonyProject, "vendor", "acme", "foo")
+	if err := os.MkdirAll(filepath.Dir(link), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "..", "packages", "foo"), link); err != nil {
+		t.Skip(err)
+	}
+
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{".env", "public/index.php", "src/Kernel.php", "vendor/acme/foo/src/Bundle.php"}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+}
+
+func TestGetFilesToWatch_Excludes(t *testing.T) {
+	config := newTestProject(t, []string{
+		".env.local",
+		"src/Service/Variant/Picker.php",
+		"src/.github-integration/Client.php",
+		"src/.git/HEAD",
+		"src/Core/Fixtures/Users.php",
+		"src/Blog/Fixtures/Posts.php",
+		"src/Blog/debug.log",
+		"templates/emails/welcome.mjml",
+		"templates/emails/welcome.html.twig",
+		"translations/var/messages.en.yaml",
+		"config/generated/routes.yaml",
+		"config/generated/keep/services.yaml",
+	})
+	config.DirsExclude = append(config.DirsExclude, "var/", "src/**/Fixtures", "!src/Core/Fixtures", "*.log", "templates/emails/*.mjml", ".env.local", "config/generated", "!config/generated/keep")
+
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		".env",
+		"config/generated/keep/services.yaml",
+		"public/index.php",
+		"src/.github-integration/Client.php",
+		"src/Core/Fixtures/Users.php",
+		"src/Service/Variant/Picker.php",
+		"templates/emails/welcome.html.twig",
+	}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+}
+
+func TestGetFilesToWatch_Gitignore(t *testing.T) {
+	config := newTestProject(t, []string{
+		".env.local",
+		".gitignore",
+		"config/jwt/private.pem",
+		"config/services.yaml",
+		"src/.gitignore",
+		"src/Generated/Entity.php",
+		"src/Kernel.php",
+		"src/Kernel.php.bak",
+		"translations/.cache/messages.php",
+		"translations/messages.en.yaml",
+		"vendor/acme/foo/.cache/routes.php",
+		"vendor/acme/foo/src/Bundle.php",
+	})
+	config.VendorWatch = true
+	config.VendorList = []string{"acme/foo"}
+	files := map[string]string{
+		".gitignore":        "/.env.local\n/config/jwt/*.pem\n.cache/\n/vendor/\n",
+		"src/.gitignore":    "Generated/\n",
+		".git/info/exclude": "*.bak\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(config.DirSymfonyProject, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := []string{
+		".env",
+		".env.local",
+		"config/jwt/private.pem",
+		"config/services.yaml",
+		"public/index.php",
+		"src/Generated/Entity.php",
+		"src/Kernel.php",
+		"src/Kernel.php.bak",
+		"translations/.cache/messages.php",
+		"translations/messages.en.yaml",
+		"vendor/acme/foo/.cache/routes.php",
+		"vendor/acme/foo/src/Bundle.php",
+	}
+	// The watched vendor package is kept although its parent is ignored, unlike the paths ignored inside it
+	ignored := []string{
+		".env",
+		".env.local",
+		"config/services.yaml",
+		"public/index.php",
+		"src/Kernel.php",
+		"translations/messages.en.yaml",
+		"vendor/acme/foo/src/Bundle.php",
+	}
+
+	for _, tt := range []struct {
+		name      string
+		gitignore bool
+		want      []string
+	}{
+		{name: "Disabled", want: all},
+		{name: "Enabled", gitignore: true, want: ignored},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Gitignore = tt.gitignore
+
+			files, err := GetFilesToWatch(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetFilesToWatch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFilesToWatch_TempFiles(t *testing.T) {
+	config := newTestProject(t, []string{
+		".env.swp",
+		"src/Kernel.php",
+		"src/Kernel.php___jb_tmp___",
+		"src/Kernel.php___jb_old___",
+		"src/.Kernel.php.swp",
+		"src/Kernel.php~",
+		"src/.#Kernel.php",
+		"src/#Kernel.php#",
+		"src/4913",
+		"templates/.DS_Store",
+		"templates/base.html.twig",
+	})
+
+	for _, tt := range []struct {
+		name      string
+		tempFiles []string
+		want      []string
+	}{
+		{name: "Default", tempFiles: structs.DefaultTempFiles, want: []string{".env", "public/index.php", "src/Kernel.php", "templates/base.html.twig"}},
+		{name: "Overridden", tempFiles: []string{"*~", ".DS_Store"}, want: []string{
+			".env",
+			".env.swp",
+			"public/index.php",
+			"src/#Kernel.php#",
+			"src/.#Kernel.php",
+			"src/.Kernel.php.swp",
+			"src/4913",
+			"src/Kernel.php",
+			"src/Kernel.php___jb_old___",
+			"src/Kernel.php___jb_tmp___",
+			"templates/base.html.twig",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config.TempFiles = tt.tempFiles
+
+			files, err := GetFilesToWatch(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetFilesToWatch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFilesToWatch_WatchPaths(t *testing.T) {
+	config := newTestProject(t, []string{
+		"composer.lock",
+		"config/bundles.php",
+		"lib/Legacy/Mailer.php",
+		"src/Kernel.php",
+	})
+	if err := os.Remove(filepath.Join(config.DirSymfonyProject, "public", "index.php")); err != nil {
+		t.Fatal(err)
+	}
+	config.Watch = []string{"lib", "modules?", "composer.lock", "config/bundles.php", "public/api.php?"}
+
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".env", "composer.lock", "config/bundles.php", "lib/Legacy/Mailer.php", "src/Kernel.php"}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+
+	dirs, err := GetDirsToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var single []string
+	for dir, recursive := range dirs {
+		if !recursive {
+			single = append(single, dir)
+		}
+	}
+	if got := relativeFiles(t, config, single); !reflect.DeepEqual(got, []string{".", "public"}) {
+		t.Errorf("GetDirsToWatch() not recursive = %q, want the project and public directories", got)
+	}
+	if !dirs[filepath.Join(config.DirSymfonyProject, "lib", "Legacy")] {
+		t.Errorf("GetDirsToWatch() = %v, want lib/Legacy watched recursively", dirs)
+	}
+
+	config.Watch = []string{"modules"}
+	if _, err = GetFilesToWatch(config); err == nil {
+		t.Error("GetFilesToWatch() with a missing required path should fail")
+	}
+}
+
+func TestGetDirsToWatch_OtherWorkingDirectory(t *testing.T) {
+	config := newTestProject(t, []string{"src/Controller/HomeController.php", "config/packages/framework.yaml"})
+
+	dirs, err := GetDirsToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for dir, recursive := range dirs {
+		if recursive {
+			got = append(got, dir)
+		}
+	}
+
+	want := []string{"config", "config/packages", "migrations", "src", "src/Controller", "templates", "translations"}
+	if got := relativeFiles(t, config, got); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDirsToWatch() recursive = %q, want %q", got, want)
+	}
+}
+
+func TestGetWatchMap_OtherWorkingDirectory(t *testing.T) {
+	config := newTestProject(t, []string{"src/Kernel.php"})
+
+	snapshot, err := GetWatchMap(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := snapshot[filepath.Join(config.DirSymfonyProject, "src", "Kernel.php")]; !ok || len(snapshot) != 3 {
+		t.Errorf("GetWatchMap() = %v, want the 3 project files", snapshot)
+	}
+}
@@ -0,0 +1,179 @@
+package symfony
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+// Kinds of the resources read from the .meta files.
+const (
+	ResourceFile      = "file"      // A file whose content is a dependency
+	ResourceExistence = "existence" // A path whose existence is a dependency, whether it exists or not
+	ResourceDirectory = "directory" // A directory whose files are dependencies, at any depth
+)
+
+// Resource is a dependency of a Symfony cache file, as listed by the .meta file written next to it by ConfigCache.
+type Resource struct {
+	Kind string
+	Path string // As seen by PHP, inside the container when the console runs in one
+}
+
+// metaCache holds the resources of the .meta files already read, as they only change on warmups.
+var metaCache = struct {
+	sync.Mutex
+	entries map[string]metaEntry
+}{entries: make(map[string]metaEntry)}
+
+// metaEntry is a .meta file read, along with the state it had.
+type metaEntry struct {
+	size      int64
+	modTime   time.Time
+	resources []Resource
+}
+
+// GetMetaFiles returns the .meta files of the cache directory of the configured environment, such as
+// var/cache/dev/App_KernelDevDebugContainer.php.meta, and of its direct subdirectories, such as the
+// translations one. They are written by the warmup, so none exists before the first one.
+func GetMetaFiles(config structs.Config) []string {
+	cacheDir := filepath.Join(config.DirSymfonyProject, "var", "cache", config.SymfonyEnv)
+
+	files, _ := filepath.Glob(filepath.Join(cacheDir, "*.meta"))
+	nested, _ := filepath.Glob(filepath.Join(cacheDir, "*", "*.meta"))
+
+	return append(files, nested...)
+}
+
+// ReadMetaResources returns the resources listed by a .meta file. The file is only parsed again once it
+// changed. The resources checked by Symfony without a path, such as the existence of a class or the value
+// of an environment variable, are left out.
+func ReadMetaResources(file string) ([]Resource, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	metaCache.Lock()
+	defer metaCache.Unlock()
+
+	if entry, ok := metaCache.entries[file]; ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.resources, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	value, err := unserializePHP(data)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the %s cache metadata: %w", file, err)
+	}
+
+	resources := metaResources(value)
+	metaCache.entries[file] = metaEntry{size: info.Size(), modTime: info.ModTime(), resources: resources}
+
+	return resources, nil
+}
+
+// metaResources converts the unserialized resource objects of a .meta file into resources.
+func metaResources(value any) []Resource {
+	var resources []Resource
+	add := func(kind string, path any) {
+		if path, ok := path.(string); ok && path != "" {
+			resources = append(resources, Resource{Kind: kind, Path: path})
+		}
+	}
+
+	var objects []any
+	switch v := value.(type) {
+	case []phpEntry:
+		for _, entry := range v {
+			objects = append(objects, entry.Value)
+		}
+	case *phpObject:
+		objects = append(objects, v)
+	}
+
+	for _, value := range objects {
+		object, ok := value.(*phpObject)
+		if !ok {
+			continue
+		}
+
+		switch object.Class[strings.LastIndexByte(object.Class, '\\')+1:] {
+		case "FileResource":
+			add(ResourceFile, object.Props["resource"])
+		case "FileExistenceResource":
+			add(ResourceExistence, object.Props["resource"])
+		case "DirectoryResource":
+			add(ResourceDirectory, object.Props["resource"])
+		case "GlobResource":
+			// The whole prefix is watched, rather than the files matching the glob pattern
+			add(ResourceDirectory, object.Props["prefix"])
+		case "ReflectionClassResource":
+			// The files declaring the class, its parents, traits and interfaces, as keys
+			files, _ := object.Props["files"].([]phpEntry)
+			for _, file := range files {
+				add(ResourceFile, file.Key)
+			}
+		case "ComposerResource":
+			// The vendor directories, whose installed packages are a dependency, as keys mapped to their mtime
+			vendors, _ := object.Props["vendors"].([]phpEntry)
+			for _, vendor := range vendors {
+				if dir, ok := vendor.Key.(string); ok {
+					add(ResourceFile, dir+"/composer/installed.json")
+				}
+			}
+		}
+	}
+
+	return resources
+}
+
+// getMetaWatchSet returns the files and directories listed by the .meta files, along with the extra paths
+// of the watch setting. The watched vendor packages and the exclude patterns of the vendor directory do
+// not apply, as the resources are exactly the dependencies of the cache, including vendor bundle configs.
+func getMetaWatchSet(config structs.Config, metaFiles []string) (watchSet, error) {
+	// The cache directory is never watched, as the warmup writes it
+	set := watchSet{excludes: append([]string{"/var/cache/"}, config.DirsExclude...)}
+	cacheDir := filepath.Join(config.DirSymfonyProject, "var", "cache")
+
+	// The project root holds the .env files
+	set.addDir(config.DirSymfonyProject)
+
+	for _, metaFile := range metaFiles {
+		resources, err := ReadMetaResources(metaFile)
+		if err != nil {
+			return set, err
+		}
+
+		for _, resource := range resources {
+			path := UnmapPath(config, resource.Path)
+			if !filepath.IsAbs(path) {
+				path = projectPath(config, path)
+			}
+			if isWithin(path, cacheDir) {
+				continue
+			}
+
+			if info, err := os.Stat(path); resource.Kind == ResourceDirectory && err == nil && info.IsDir() {
+				set.addRoot(path, true)
+				continue
+			}
+			// A missing file is watched for its creation, like the missing path of an existence resource
+			set.addFile(path)
+		}
+	}
+
+	if err := set.addPaths(config, config.WatchPaths()); err != nil {
+		return set, err
+	}
+	set.removeRootFiles()
+
+	return set, nil
+}
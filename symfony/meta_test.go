@@ -0,0 +1,161 @@
+package symfony
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// phpString serializes a string like PHP.
+func phpString(s string) string {
+	return fmt.Sprintf(`s:%d:"%s";`, len(s), s)
+}
+
+// phpResource serializes a Symfony resource object holding the given private properties, already serialized.
+func phpResource(class string, props ...string) string {
+	class = `Symfony\Component\Config\Resource\` + class
+
+	var b strings.Builder
+	for i := 0; i < len(props); i += 2 {
+		b.WriteString(phpString("\x00" + class + "\x00" + props[i]))
+		b.WriteString(props[i+1])
+	}
+
+	return fmt.Sprintf(`O:%d:"%s":%d:{%s}`, len(class), class, len(props)/2, b.String())
+}
+
+// phpList serializes a list of already serialized values.
+func phpList(values ...string) string {
+	var b strings.Builder
+	for i, value := range values {
+		b.WriteString(fmt.Sprintf("i:%d;%s", i, value))
+	}
+
+	return fmt.Sprintf("a:%d:{%s}", len(values), b.String())
+}
+
+// writeMeta writes a .meta file in the dev cache directory of the project.
+func writeMeta(t *testing.T, projectDir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(projectDir, "var", "cache", "dev", name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// containerMeta lists the resources of a container, as seen from a container mounting the project on /app.
+var containerMeta = phpList(
+	phpResource("FileResource", "resource", phpString("/app/config/services.yaml")),
+	phpResource("GlobResource", "prefix", phpString("/app/config/packages"), "pattern", phpString("/**/*.{php,yaml}"), "recursive", "b:1;"),
+	phpResource("DirectoryResource", "resource", phpString("/app/vendor/acme/blog/Resources/config"), "pattern", phpString(`/\.xml$/`)),
+	phpResource("ReflectionClassResource", "files", `a:1:{`+phpString("/app/src/Kernel.php")+`N;}`, "className", phpString(`App\Kernel`)),
+	phpResource("ComposerResource", "vendors", `a:1:{`+phpString("/app/vendor")+`i:1718000000;}`),
+	phpResource("FileExistenceResource", "resource", phpString("/app/config/routes.yaml"), "exists", "b:0;"),
+	phpResource("ClassExistenceResource", "resource", phpString(`App\Missing`), "exists", "N;"),
+	phpResource("FileResource", "resource", phpString("/app/var/cache/dev/App_KernelDevDebugContainer.php")),
+)
+
+func TestReadMetaResources(t *testing.T) {
+	file := writeMeta(t, t.TempDir(), "App_KernelDevDebugContainer.php.meta", containerMeta)
+
+	want := []Resource{
+		{Kind: ResourceFile, Path: "/app/config/services.yaml"},
+		{Kind: ResourceDirectory, Path: "/app/config/packages"},
+		{Kind: ResourceDirectory, Path: "/app/vendor/acme/blog/Resources/config"},
+		{Kind: ResourceFile, Path: "/app/src/Kernel.php"},
+		{Kind: ResourceFile, Path: "/app/vendor/composer/installed.json"},
+		{Kind: ResourceExistence, Path: "/app/config/routes.yaml"},
+		{Kind: ResourceFile, Path: "/app/var/cache/dev/App_KernelDevDebugContainer.php"},
+	}
+	resources, err := ReadMetaResources(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resources, want) {
+		t.Errorf("ReadMetaResources() = %v, want %v", resources, want)
+	}
+
+	if err = os.WriteFile(file, []byte("a:1:{i:0;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadMetaResources(file); err == nil {
+		t.Error("ReadMetaResources() on a truncated file should fail")
+	}
+}
+
+func TestGetFilesToWatch_MetaStrategy(t *testing.T) {
+	config := newTestProject(t, []string{
+		"config/packages/framework.yaml",
+		"config/services.yaml",
+		"src/Controller/HomeController.php",
+		"src/Kernel.php",
+		"templates/base.html.twig",
+		"translations/messages.en.yaml",
+		"vendor/acme/blog/Resources/config/services.xml",
+		"vendor/composer/installed.json",
+		"var/cache/dev/App_KernelDevDebugContainer.php",
+	})
+	config.Strategy = "meta"
+	config.PathMap = config.DirSymfonyProject + ":/app"
+
+	// Before the first warmup, the watched directories are used
+	files, err := GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".env",
+		"config/packages/framework.yaml",
+		"config/services.yaml",
+		"public/index.php",
+		"src/Controller/HomeController.php",
+		"src/Kernel.php",
+		"templates/base.html.twig",
+		"translations/messages.en.yaml",
+	}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() without .meta file = %q, want %q", got, want)
+	}
+
+	writeMeta(t, config.DirSymfonyProject, "App_KernelDevDebugContainer.php.meta", containerMeta)
+	writeMeta(t, config.DirSymfonyProject, filepath.Join("translations", "catalogue.en.meta"), phpList(
+		phpResource("FileResource", "resource", phpString("/app/translations/messages.en.yaml")),
+	))
+
+	files, err = GetFilesToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		".env",
+		"config/packages/framework.yaml",
+		"config/services.yaml",
+		"src/Kernel.php",
+		"translations/messages.en.yaml",
+		"vendor/acme/blog/Resources/config/services.xml",
+		"vendor/composer/installed.json",
+	}
+	if got := relativeFiles(t, config, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFilesToWatch() = %q, want %q", got, want)
+	}
+
+	dirs, err := GetDirsToWatch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recursive, ok := dirs[filepath.Join(config.DirSymfonyProject, "config")]; !ok || recursive {
+		t.Errorf("GetDirsToWatch() = %v, want config watched for the missing routes.yaml", dirs)
+	}
+	if _, ok := dirs[filepath.Join(config.DirSymfonyProject, "var", "cache", "dev")]; ok {
+		t.Errorf("GetDirsToWatch() = %v, want the cache directory not watched", dirs)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package symfony
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// processWaitDelay bounds how long a killed command may keep its output pipes open.
+const processWaitDelay = 2 * time.Second
+
+// setProcessGroup starts the command in a new process group and makes the context cancellation
+// kill the whole group, including the processes spawned by the console. A cancellation already set by
+// the executor, such as the one killing the console inside a container, runs first.
+func setProcessGroup(cmd *exec.Cmd) {
+	stop := cmd.Cancel
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if stop != nil {
+			_ = stop()
+		}
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = processWaitDelay
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package symfony
+
+import (
+	"os/exec"
+	"time"
+)
+
+// processWaitDelay bounds how long a killed command may keep its output pipes open.
+const processWaitDelay = 2 * time.Second
+
+// setProcessGroup only bounds the wait for the output pipes: Windows has no process groups to signal,
+// the context cancellation kills the console process itself.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = processWaitDelay
+}
@@ -0,0 +1,188 @@
+package symfony
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// filesHashed counts the files hashed by HashFile.
+var filesHashed atomic.Int64
+
+// Snapshot maps each watched file to the state it had when the watch set was scanned.
+type Snapshot map[string]FileState
+
+// FileState holds what is known about a watched file at scan time.
+type FileState struct {
+	Size    int64
+	ModTime time.Time
+	Hash    string // Content hash, only computed when hashing is enabled
+}
+
+// Equal reports whether two states describe the same file content. When both states carry a
+// content hash, only the hashes are compared, so touching a file does not count as a change.
+// Otherwise, the file is considered unchanged if its size and modification time did not move.
+func (s FileState) Equal(other FileState) bool {
+	if s.Hash != "" && other.Hash != "" {
+		return s.Hash == other.Hash
+	}
+
+	return s.Size == other.Size && s.ModTime.Equal(other.ModTime)
+}
+
+// GetFileState stats the file and returns its state. When hashContent is true, the content hash is
+// reused from the previous state if the size and modification time did not move, and computed otherwise.
+func GetFileState(file string, previous FileState, hashContent bool) (FileState, error) {
+	stats, err := os.Stat(file)
+	if err != nil {
+		return FileState{}, err
+	}
+
+	state := FileState{Size: stats.Size(), ModTime: stats.ModTime()}
+	if !hashContent {
+		return state, nil
+	}
+
+	if previous.Hash != "" && previous.Size == state.Size && previous.ModTime.Equal(state.ModTime) {
+		state.Hash = previous.Hash
+		return state, nil
+	}
+
+	state.Hash, err = HashFile(file)
+
+	return state, err
+}
+
+// SnapshotFiles returns the state of each file with GetFileState, leaving out the files that no longer exist.
+// A file whose state can't be read, such as a file without read permission when hashing, keeps its previous
+// state, if any, so that it does not count as a change. The snapshot is then returned along with a
+// *ScanError listing those files, to be reported as a warning.
+func SnapshotFiles(files []string, previous Snapshot, hashContent bool) (Snapshot, error) {
+	snapshot := make(Snapshot, len(files))
+	var scanErr ScanError
+
+	for _, file := range files {
+		state, err := GetFileState(file, previous[file], hashContent)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			scanErr.Files = append(scanErr.Files, fmt.Errorf("%s: %w", file, err))
+			if previousState, ok := previous[file]; ok {
+				snapshot[file] = previousState
+			}
+			continue
+		}
+		snapshot[file] = state
+	}
+
+	if len(scanErr.Files) > 0 {
+		return snapshot, &scanErr
+	}
+
+	return snapshot, nil
+}
+
+// ScanError lists the files skipped by a scan as their state can't be read. The snapshot returned along with
+// it is still usable.
+type ScanError struct {
+	Files []error
+}
+
+// Error returns the first unreadable files.
+func (e *ScanError) Error() string {
+	const maxFiles = 3
+
+	messages := make([]string, 0, maxFiles)
+	for i := 0; i < len(e.Files) && i < maxFiles; i++ {
+		messages = append(messages, e.Files[i].Error())
+	}
+	if len(e.Files) > maxFiles {
+		messages = append(messages, fmt.Sprintf("and %d more", len(e.Files)-maxFiles))
+	}
+
+	return fmt.Sprintf("can't get stats for %d file(s), check the project permissions: %s", len(e.Files), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the error of each file.
+func (e *ScanError) Unwrap() []error {
+	return e.Files
+}
+
+// HashFile returns a fast, non-cryptographic hash of the file content.
+func HashFile(file string) (string, error) {
+	filesHashed.Add(1)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := crc64.New(crcTable)
+	if _, err = io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%016x", hash.Sum64()), nil
+}
+
+// FilesHashed returns the number of files hashed since the program started, as hashing reads the whole
+// content of the file while a stat is enough to tell that it did not move.
+func FilesHashed() int64 {
+	return filesHashed.Load()
+}
+
+// Changes lists the files that differ between two snapshots. Each list is sorted.
+type Changes struct {
+	Added    []string // Files present only in the current snapshot
+	Modified []string // Files present in both snapshots with a different state
+	Deleted  []string // Files present only in the previous snapshot
+}
+
+// Diff compares the previous snapshot with the current one and classifies every differing file
+// as added, modified or deleted.
+func Diff(previous, current Snapshot) Changes {
+	var changes Changes
+
+	for file, state := range current {
+		previousState, ok := previous[file]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, file)
+		case !previousState.Equal(state):
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+
+	for file := range previous {
+		if _, ok := current[file]; !ok {
+			changes.Deleted = append(changes.Deleted, file)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+
+	return changes
+}
+
+// Len returns the total number of changed files.
+func (c Changes) Len() int {
+	return len(c.Added) + len(c.Modified) + len(c.Deleted)
+}
+
+// IsEmpty reports whether no file changed.
+func (c Changes) IsEmpty() bool {
+	return c.Len() == 0
+}
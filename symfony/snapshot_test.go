@@ -0,0 +1,186 @@
+package symfony
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	v1 := FileState{Size: 1, ModTime: time.Unix(1, 0)}
+	v2 := FileState{Size: 1, ModTime: time.Unix(2, 0)}
+
+	tests := []struct {
+		name     string
+		previous Snapshot
+		current  Snapshot
+		want     Changes
+	}{
+		{
+			name:     "No changes",
+			previous: Snapshot{"src/Kernel.php": v1},
+			current:  Snapshot{"src/Kernel.php": v1},
+			want:     Changes{},
+		},
+		{
+			name:     "Added, modified and deleted files",
+			previous: Snapshot{"src/Kernel.php": v1, "config/services.yaml": v1, "templates/base.html.twig": v1},
+			current:  Snapshot{"src/Kernel.php": v2, "config/services.yaml": v1, "src/Controller/HomeController.php": v1, "src/Entity/User.php": v1},
+			want: Changes{
+				Added:    []string{"src/Controller/HomeController.php", "src/Entity/User.php"},
+				Modified: []string{"src/Kernel.php"},
+				Deleted:  []string{"templates/base.html.twig"},
+			},
+		},
+		{
+			name:     "Nil previous snapshot",
+			previous: nil,
+			current:  Snapshot{"src/Kernel.php": v1},
+			want:     Changes{Added: []string{"src/Kernel.php"}},
+		},
+		{
+			name:     "Touched file with the same hash",
+			previous: Snapshot{"src/Kernel.php": {Size: 1, ModTime: time.Unix(1, 0), Hash: "a"}},
+			current:  Snapshot{"src/Kernel.php": {Size: 1, ModTime: time.Unix(2, 0), Hash: "a"}},
+			want:     Changes{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.previous, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+			if got.IsEmpty() != (tt.want.Len() == 0) {
+				t.Errorf("Changes.IsEmpty() = %v, want %v", got.IsEmpty(), tt.want.Len() == 0)
+			}
+		})
+	}
+}
+
+func TestFileState_Equal(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     FileState
+		b     FileState
+		equal bool
+	}{
+		{name: "Same size and time", a: FileState{Size: 1, ModTime: time.Unix(1, 0)}, b: FileState{Size: 1, ModTime: time.Unix(1, 0)}, equal: true},
+		{name: "Different time", a: FileState{Size: 1, ModTime: time.Unix(1, 0)}, b: FileState{Size: 1, ModTime: time.Unix(2, 0)}, equal: false},
+		{name: "Different size", a: FileState{Size: 1, ModTime: time.Unix(1, 0)}, b: FileState{Size: 2, ModTime: time.Unix(1, 0)}, equal: false},
+		{name: "Same hash, different time", a: FileState{Size: 1, ModTime: time.Unix(1, 0), Hash: "a"}, b: FileState{Size: 1, ModTime: time.Unix(2, 0), Hash: "a"}, equal: true},
+		{name: "Different hash", a: FileState{Size: 1, ModTime: time.Unix(1, 0), Hash: "a"}, b: FileState{Size: 1, ModTime: time.Unix(1, 0), Hash: "b"}, equal: false},
+		{name: "Hash on one side only", a: FileState{Size: 1, ModTime: time.Unix(1, 0), Hash: "a"}, b: FileState{Size: 1, ModTime: time.Unix(2, 0)}, equal: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Equal(tt.b); got != tt.equal {
+				t.Errorf("FileState.Equal() = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func TestGetFileState(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "services.yaml")
+	if err := os.WriteFile(file, []byte("services:"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := GetFileState(file, FileState{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Hash != "" || state.Size != int64(len("services:")) {
+		t.Errorf("GetFileState() without hashing = %v", state)
+	}
+
+	hashed, err := GetFileState(file, FileState{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashed.Hash == "" {
+		t.Fatal("GetFileState() with hashing returned an empty hash")
+	}
+
+	// An unchanged size and modification time reuses the previous hash without reading the file.
+	previous := hashed
+	previous.Hash = "cached"
+	if got, _ := GetFileState(file, previous, true); got.Hash != "cached" {
+		t.Errorf("GetFileState() hash = %v, want the cached hash", got.Hash)
+	}
+
+	// Touching the file re-hashes it, but the content hash stays the same.
+	later := hashed.ModTime.Add(time.Hour)
+	if err = os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := GetFileState(file, hashed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !touched.Equal(hashed) || touched.ModTime.Equal(hashed.ModTime) {
+		t.Errorf("GetFileState() after touch = %v, want same hash as %v", touched, hashed)
+	}
+
+	if _, err = GetFileState(filepath.Join(t.TempDir(), "missing"), FileState{}, true); err == nil {
+		t.Error("GetFileState() on a missing file should fail")
+	}
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "Kernel.php")
+	if err := os.WriteFile(kept, []byte("<?php"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	locked := filepath.Join(dir, "locked", "Secret.php")
+	if err := os.MkdirAll(filepath.Dir(locked), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(locked, []byte("<?php"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file removed after the walk is left out, like a deleted file.
+	snapshot, err := SnapshotFiles([]string{kept, filepath.Join(dir, "Kernel.php___jb_tmp___")}, nil, true)
+	if err != nil {
+		t.Fatalf("SnapshotFiles() error = %v, want nil", err)
+	}
+	if _, ok := snapshot[kept]; !ok || len(snapshot) != 1 {
+		t.Errorf("SnapshotFiles() = %v, want only %s", snapshot, kept)
+	}
+
+	// A file that can't be read keeps its previous state, the scan going on
+	previous := Snapshot{kept: FileState{Size: 1, Hash: "previous"}, dir: FileState{Size: 2, Hash: "dir"}}
+	snapshot, err = SnapshotFiles([]string{kept, dir}, previous, true)
+	var scanErr *ScanError
+	if !errors.As(err, &scanErr) || len(scanErr.Files) != 1 {
+		t.Fatalf("SnapshotFiles() error = %v, want a *ScanError for %s", err, dir)
+	}
+	if snapshot[dir] != previous[dir] || snapshot[kept].Hash == "previous" {
+		t.Errorf("SnapshotFiles() = %v, want the previous state of %s only", snapshot, dir)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	if err = os.Chmod(locked, 0o000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o644)
+
+	snapshot, err = SnapshotFiles([]string{kept, locked}, nil, true)
+	if !errors.As(err, &scanErr) || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("SnapshotFiles() error = %v, want a *ScanError for the permission denied", err)
+	}
+	if _, ok := snapshot[kept]; !ok || len(snapshot) != 1 {
+		t.Errorf("SnapshotFiles() = %v, want only %s", snapshot, kept)
+	}
+}
@@ -0,0 +1,97 @@
+package symfony
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// LiveOutput is where the console output is streamed in verbose mode.
+var LiveOutput io.Writer = os.Stdout
+
+// step is the label and color prefixing the streamed output of a console command.
+type step struct {
+	label string
+	color color.Attribute
+}
+
+// steps maps the console command names to the warmup step they belong to.
+var steps = map[string]step{
+	cacheClearCommandName:  {label: "clear", color: color.FgHiYellow},
+	cachePoolCommandName:   {label: "pool", color: color.FgHiMagenta},
+	cacheWarmupCommandName: {label: "warmup", color: color.FgHiCyan},
+}
+
+// getStep returns the step the console command belongs to, or a generic "console" step.
+func getStep(command Command) step {
+	if s, ok := steps[command.Name]; ok {
+		return s
+	}
+
+	return step{label: "console", color: color.FgHiBlack}
+}
+
+// StreamWriter writes each complete line it receives to an output, prefixed by the step label,
+// while keeping a copy of everything written for error reporting.
+type StreamWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	prefix  string
+	pending []byte       // Incomplete last line
+	output  bytes.Buffer // Everything written so far
+}
+
+// NewStreamWriter creates a StreamWriter printing to out with the given step label and color.
+func NewStreamWriter(out io.Writer, label string, attribute color.Attribute) *StreamWriter {
+	return &StreamWriter{
+		out:    out,
+		prefix: fmt.Sprintf("   %s │ ", color.New(attribute).Sprintf("%-7s", label)),
+	}
+}
+
+// Write captures p and prints the lines it completes.
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.output.Write(p)
+	w.pending = append(w.pending, p...)
+
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.printLine(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush prints the last line if it did not end with a newline.
+func (w *StreamWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 {
+		w.printLine(w.pending)
+		w.pending = nil
+	}
+}
+
+// String returns everything written so far.
+func (w *StreamWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.output.String()
+}
+
+func (w *StreamWriter) printLine(line []byte) {
+	_, _ = fmt.Fprintf(w.out, "%s%s\n", w.prefix, bytes.TrimRight(line, "\r"))
+}
@@ -0,0 +1,83 @@
+package symfony
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestStreamWriter(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = old })
+
+	var out bytes.Buffer
+	w := NewStreamWriter(&out, "warmup", color.FgHiCyan)
+
+	_, _ = w.Write([]byte("Warming up the cache"))
+	if out.Len() != 0 {
+		t.Errorf("StreamWriter printed an incomplete line: %q", out.String())
+	}
+
+	_, _ = w.Write([]byte(" for the dev environment\r\n [OK] Cache"))
+	_, _ = w.Write([]byte(" warmed up\n"))
+	_, _ = w.Write([]byte("trailing"))
+	w.Flush()
+
+	wantOut := "   warmup  │ Warming up the cache for the dev environment\n   warmup  │  [OK] Cache warmed up\n   warmup  │ trailing\n"
+	if out.String() != wantOut {
+		t.Errorf("StreamWriter output = %q, want %q", out.String(), wantOut)
+	}
+
+	wantCaptured := "Warming up the cache for the dev environment\r\n [OK] Cache warmed up\ntrailing"
+	if w.String() != wantCaptured {
+		t.Errorf("StreamWriter.String() = %q, want %q", w.String(), wantCaptured)
+	}
+}
+
+func TestGetStep(t *testing.T) {
+	tests := []struct {
+		command Command
+		want    string
+	}{
+		{command: CacheClearCommand(), want: "clear"},
+		{command: CachePoolClearCommand("--all"), want: "pool"},
+		{command: CacheWarmupCommand(), want: "warmup"},
+		{command: VersionCommand(), want: "console"},
+		{command: Command{Name: "cache:warmup:custom"}, want: "console"},
+	}
+
+	for _, tt := range tests {
+		if got := getStep(tt.command).label; got != tt.want {
+			t.Errorf("getStep(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestRunCommand_Verbose(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = old })
+
+	var out bytes.Buffer
+	rescueOutput := LiveOutput
+	LiveOutput = &out
+	defer func() { LiveOutput = rescueOutput }()
+
+	config := newFakeConsole(t, "echo one\necho two >&2\nexit 3")
+	config.Verbose = true
+
+	_, err := RunCommand(context.Background(), config, CacheWarmupCommand())
+
+	if want := "   warmup  │ one\n   warmup  │ two\n"; out.String() != want {
+		t.Errorf("RunCommand() streamed %q, want %q", out.String(), want)
+	}
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Output != "one\ntwo\n" {
+		t.Errorf("RunCommand() error = %v, want a *CommandError with the captured output", err)
+	}
+}
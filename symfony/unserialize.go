@@ -0,0 +1,216 @@
+package symfony
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// phpObject is an object decoded by unserializePHP. Its property names are stripped of the prefix PHP
+// adds to private and protected properties.
+type phpObject struct {
+	Class string
+	Props map[string]any
+}
+
+// phpEntry is an entry of an array decoded by unserializePHP, the key being an int64 or a string.
+type phpEntry struct {
+	Key   any
+	Value any
+}
+
+// phpDecoder reads the format of the PHP serialize function.
+type phpDecoder struct {
+	data []byte
+	pos  int
+}
+
+// unserializePHP decodes a value serialized by PHP. Arrays are decoded as []phpEntry, keeping their order,
+// objects as *phpObject, integers as int64 and floats as float64. Objects implementing Serializable and
+// references are decoded as nil, their content being opaque.
+func unserializePHP(data []byte) (any, error) {
+	d := &phpDecoder{data: data}
+
+	value, err := d.value()
+	if err != nil {
+		return nil, fmt.Errorf("invalid serialized data at offset %d: %w", d.pos, err)
+	}
+
+	return value, nil
+}
+
+// value decodes the value starting at the current position.
+func (d *phpDecoder) value() (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+
+	kind := d.data[d.pos]
+	if kind == 'N' {
+		return nil, d.expect("N;")
+	}
+	if err := d.expect(string(kind) + ":"); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case 'b':
+		value, err := d.until(';')
+		return value == "1", err
+	case 'i':
+		value, err := d.until(';')
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(value, 10, 64)
+	case 'd':
+		value, err := d.until(';')
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseFloat(value, 64) // Also reads INF, -INF and NAN
+	case 's':
+		value, err := d.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return value, d.expect(";")
+	case 'E':
+		value, err := d.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return value, d.expect(";")
+	case 'r', 'R':
+		_, err := d.until(';')
+		return nil, err
+	case 'a':
+		return d.entries()
+	case 'O':
+		class, err := d.quoted()
+		if err != nil {
+			return nil, err
+		}
+		if err = d.expect(":"); err != nil {
+			return nil, err
+		}
+		entries, err := d.entries()
+		if err != nil {
+			return nil, err
+		}
+
+		object := &phpObject{Class: class, Props: make(map[string]any, len(entries))}
+		for _, entry := range entries {
+			name := fmt.Sprint(entry.Key)
+			if i := strings.LastIndexByte(name, 0); i >= 0 {
+				name = name[i+1:]
+			}
+			object.Props[name] = entry.Value
+		}
+		return object, nil
+	case 'C':
+		if _, err := d.quoted(); err != nil {
+			return nil, err
+		}
+		if err := d.expect(":"); err != nil {
+			return nil, err
+		}
+		length, err := d.length(':')
+		if err != nil {
+			return nil, err
+		}
+		if err = d.expect("{"); err != nil {
+			return nil, err
+		}
+		if length < 0 || length > len(d.data)-d.pos {
+			return nil, fmt.Errorf("unexpected end of data")
+		}
+		d.pos += length
+		return nil, d.expect("}")
+	default:
+		return nil, fmt.Errorf("unknown type %q", kind)
+	}
+}
+
+// entries decodes the count and the key-value pairs of an array or an object, after the type prefix.
+func (d *phpDecoder) entries() ([]phpEntry, error) {
+	count, err := d.length(':')
+	if err != nil {
+		return nil, err
+	}
+	if err = d.expect("{"); err != nil {
+		return nil, err
+	}
+
+	// The count is only trusted up to the remaining data, so that a corrupt one cannot exhaust the memory
+	entries := make([]phpEntry, 0, min(count, len(d.data)-d.pos))
+	for i := 0; i < count; i++ {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, phpEntry{Key: key, Value: value})
+	}
+
+	return entries, d.expect("}")
+}
+
+// quoted decodes a length-prefixed double-quoted string, such as 5:"hello". The length is in bytes.
+func (d *phpDecoder) quoted() (string, error) {
+	length, err := d.length(':')
+	if err != nil {
+		return "", err
+	}
+	if err = d.expect(`"`); err != nil {
+		return "", err
+	}
+	if length < 0 || length > len(d.data)-d.pos {
+		return "", fmt.Errorf("unexpected end of data")
+	}
+	value := string(d.data[d.pos : d.pos+length])
+	d.pos += length
+
+	return value, d.expect(`"`)
+}
+
+// length decodes a non-negative integer followed by the delimiter.
+func (d *phpDecoder) length(delimiter byte) (int, error) {
+	value, err := d.until(delimiter)
+	if err != nil {
+		return 0, err
+	}
+
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 0 {
+		return 0, fmt.Errorf("invalid length %q", value)
+	}
+
+	return length, nil
+}
+
+// until returns the data up to the delimiter, which is consumed.
+func (d *phpDecoder) until(delimiter byte) (string, error) {
+	i := bytes.IndexByte(d.data[d.pos:], delimiter)
+	if i < 0 {
+		return "", fmt.Errorf("missing %q", delimiter)
+	}
+	value := string(d.data[d.pos : d.pos+i])
+	d.pos += i + 1
+
+	return value, nil
+}
+
+// expect consumes the given token.
+func (d *phpDecoder) expect(token string) error {
+	if !bytes.HasPrefix(d.data[d.pos:], []byte(token)) {
+		return fmt.Errorf("expected %q", token)
+	}
+	d.pos += len(token)
+
+	return nil
+}
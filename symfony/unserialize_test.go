@@ -0,0 +1,54 @@
+package symfony
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUnserializePHP(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    any
+		wantErr bool
+	}{
+		{name: "Null", data: "N;", want: nil},
+		{name: "Boolean", data: "b:1;", want: true},
+		{name: "Integer", data: "i:-42;", want: int64(-42)},
+		{name: "Float", data: "d:0.5;", want: 0.5},
+		{name: "Infinity", data: "d:INF;", want: math.Inf(1)},
+		{name: "Multibyte string", data: `s:5:"été";`, want: "été"},
+		{name: "String holding delimiters", data: `s:6:"a";b:{";`, want: `a";b:{`},
+		{name: "Array", data: `a:2:{i:0;s:1:"a";s:1:"k";b:0;}`, want: []phpEntry{{Key: int64(0), Value: "a"}, {Key: "k", Value: false}}},
+		{
+			name: "Object with private and protected properties",
+			data: "O:3:\"Foo\":3:{s:8:\"\x00Foo\x00bar\";i:1;s:6:\"\x00*\x00baz\";N;s:3:\"qux\";a:0:{}}",
+			want: &phpObject{Class: "Foo", Props: map[string]any{"bar": int64(1), "baz": nil, "qux": []phpEntry{}}},
+		},
+		{name: "Serializable object", data: `a:1:{i:0;C:11:"ArrayObject":21:{x:i:0;a:0:{};m:a:0:{}}}`, want: []phpEntry{{Key: int64(0), Value: nil}}},
+		{name: "Reference", data: `a:2:{i:0;s:1:"a";i:1;R:2;}`, want: []phpEntry{{Key: int64(0), Value: "a"}, {Key: int64(1), Value: nil}}},
+		{name: "Enum", data: `E:11:"Suit:Hearts";`, want: "Suit:Hearts"},
+		{name: "Truncated string", data: `s:10:"short";`, wantErr: true},
+		{name: "Truncated array", data: `a:2:{i:0;s:1:"a";`, wantErr: true},
+		{name: "Corrupt array count", data: `a:99999999999999:{`, wantErr: true},
+		{name: "Corrupt object count", data: `O:8:"stdClass":99999999999999:{`, wantErr: true},
+		{name: "Corrupt string length", data: `s:9223372036854775807:"abc";`, wantErr: true},
+		{name: "Corrupt class name length", data: `C:9223372036854775807:"a":1:{x}`, wantErr: true},
+		{name: "Corrupt serialized data length", data: `C:1:"a":9223372036854775807:{x}`, wantErr: true},
+		{name: "Unknown type", data: "x:1;", wantErr: true},
+		{name: "Empty", data: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unserializePHP([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unserializePHP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unserializePHP() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/structs"
+	"github.com/lettland/cache-warmer/symfony"
+)
+
+// warmDescription is the help of the warm command, listing its exit statuses for deploy scripts.
+var warmDescription = fmt.Sprintf(`Run the configured cache clear, cache removal, pool clear and warmup steps once on the Symfony
+project at path, print how long each step took, then exit. The exit status tells what failed:
+  %-3d success
+  %-3d invalid configuration
+  %-3d project directory not found
+  %-3d symfony console not found
+  %-3d cache clear failed
+  %-3d pool clear failed
+  %-3d cache warmup failed
+  %-3d interrupted`, ExitOK, ExitFailure, ExitProjectNotFound, ExitConsoleNotFound, ExitClearFailed, ExitPoolClearFailed, ExitWarmupFailed, ExitCanceled)
+
+// StepResult is the outcome of a warmup step run by the warm command.
+type StepResult struct {
+	Step     symfony.WarmupStep
+	Duration time.Duration
+	Err      error
+	Skipped  bool // Whether the step did not run because a previous one failed
+}
+
+// RunSteps runs the warmup steps in order and times each of them. Once a step fails, the remaining
+// steps are skipped.
+func RunSteps(ctx context.Context, config structs.Config, steps []symfony.WarmupStep) []StepResult {
+	results := make([]StepResult, 0, len(steps))
+	failed := false
+
+	for _, step := range steps {
+		if failed {
+			results = append(results, StepResult{Step: step, Skipped: true})
+			continue
+		}
+
+		start := time.Now()
+		_, err := step.Run(ctx, config)
+		results = append(results, StepResult{Step: step, Duration: time.Since(start), Err: err})
+		failed = err != nil
+	}
+
+	return results
+}
+
+// StepsExitCode returns the exit status matching the first failed step, or ExitOK if every step succeeded.
+func StepsExitCode(ctx context.Context, results []StepResult) int {
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+
+		var stepErr *symfony.StepError
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			return ExitCanceled
+		case !errors.As(result.Err, &stepErr):
+			return ExitFailure
+		case stepErr.Kind == symfony.StepClear || stepErr.Kind == symfony.StepRemove:
+			return ExitClearFailed
+		case stepErr.Kind == symfony.StepPool:
+			return ExitPoolClearFailed
+		default:
+			return ExitWarmupFailed
+		}
+	}
+
+	return ExitOK
+}
+
+// FormatSteps returns one line per step with its kind, command, duration and result, columns aligned.
+func FormatSteps(results []StepResult) []string {
+	commandWidth, durationWidth := 0, 0
+	durations := make([]string, len(results))
+	for i, result := range results {
+		durations[i] = "-"
+		if !result.Skipped {
+			durations[i] = FormatDuration(result.Duration.Milliseconds())
+		}
+		commandWidth = max(commandWidth, len(result.Step.Command))
+		durationWidth = max(durationWidth, len(durations[i]))
+	}
+
+	lines := make([]string, 0, len(results))
+	for i, result := range results {
+		status := color.New(color.FgGreen).Sprint("done")
+		switch {
+		case result.Skipped:
+			status = color.New(color.FgHiBlack).Sprint("skipped")
+		case result.Err != nil:
+			status = color.New(color.FgHiRed).Sprint("failed")
+		}
+
+		lines = append(lines, fmt.Sprintf("   %-7s │ %-*s  %-*s  %s", result.Step.Kind, commandWidth, result.Step.Command, durationWidth, durations[i], status))
+	}
+
+	return lines
+}
+
+// runWarm runs each warmup step once, prints a timing summary and returns the exit status matching the
+// first failed step, so that deploy scripts can tell a failed pool clear from a failed warmup.
+func runWarm(flags *flag.FlagSet) int {
+	config, code := prepareConsole(flags, false)
+	if code != ExitOK {
+		return code
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	results := RunSteps(ctx, config, symfony.WarmupSteps(config))
+	elapsed := time.Since(start)
+
+	fmt.Println(" > Steps:")
+	for _, line := range FormatSteps(results) {
+		fmt.Println(line)
+	}
+
+	code = StepsExitCode(ctx, results)
+	switch code {
+	case ExitOK:
+		fmt.Println(fmt.Sprintf(" > %s in %s", color.New(color.FgGreen).Sprintf("Done"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+	case ExitCanceled:
+		fmt.Println(fmt.Sprintf(" > %s after %s", color.New(color.FgHiYellow).Sprintf("Canceled"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+	default:
+		fmt.Println(fmt.Sprintf(" > %s after %s", color.New(color.FgHiRed).Sprintf("Failed"), color.New(color.FgHiYellow).Sprintf("%s", FormatDuration(elapsed.Milliseconds()))))
+		for _, result := range results {
+			if result.Err != nil {
+				PrintCommandError(result.Err)
+			}
+		}
+	}
+
+	return code
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/lettland/cache-warmer/symfony"
+)
+
+func TestRunSteps(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		wantCode    int
+		wantSkipped []bool
+	}{
+		{name: "Success", script: "exit 0", wantCode: ExitOK, wantSkipped: []bool{false, false, false}},
+		{name: "Clear failed", script: `[ "$1" = "cache:clear" ] && exit 1; exit 0`, wantCode: ExitClearFailed, wantSkipped: []bool{false, true, true}},
+		{name: "Pool clear failed", script: `[ "$1" = "cache:pool:clear" ] && exit 1; exit 0`, wantCode: ExitPoolClearFailed, wantSkipped: []bool{false, false, true}},
+		{name: "Warmup failed", script: `[ "$1" = "cache:warmup" ] && exit 1; exit 0`, wantCode: ExitWarmupFailed, wantSkipped: []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newFakeProject(t, tt.script)
+			config.ClearCache = true
+			config.PoolsProvided = true
+			config.Pools = []string{"cache.app"}
+
+			ctx := context.Background()
+			results := RunSteps(ctx, config, symfony.WarmupSteps(config))
+
+			var skipped []bool
+			for _, result := range results {
+				skipped = append(skipped, result.Skipped)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("RunSteps() skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+
+			if got := StepsExitCode(ctx, results); got != tt.wantCode {
+				t.Errorf("StepsExitCode() = %v, want %v", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestStepsExitCode_Canceled(t *testing.T) {
+	config := newFakeProject(t, "sleep 5")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	results := RunSteps(ctx, config, symfony.WarmupSteps(config))
+
+	if got := StepsExitCode(ctx, results); got != ExitCanceled {
+		t.Errorf("StepsExitCode() = %v, want %v", got, ExitCanceled)
+	}
+}
+
+func TestFormatSteps(t *testing.T) {
+	old := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = old }()
+
+	config := newFakeProject(t, "")
+	config.PoolsProvided = true
+	config.Pools = []string{"cache.app"}
+	steps := symfony.WarmupSteps(config)
+
+	results := []StepResult{
+		{Step: steps[0], Duration: 1500 * time.Millisecond, Err: context.DeadlineExceeded},
+		{Step: steps[1], Skipped: true},
+	}
+
+	got := FormatSteps(results)
+	want := []string{
+		"   pool    │ cache:pool:clear cache.app  1 second(s), 500 millisecond(s)  failed",
+		"   warmup  │ cache:warmup                -                                skipped",
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("FormatSteps() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// maxUserWatchesPath holds the number of inotify watches a user may hold across all of its processes.
+const maxUserWatchesPath = "/proc/sys/fs/inotify/max_user_watches"
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// Inotify is the Linux event-driven Watcher. Each watched directory holds one inotify watch.
+type Inotify struct {
+	file    *os.File
+	fd      int
+	mu      sync.Mutex
+	watches map[int]inotifyWatch // Watch descriptor to directory
+	paths   map[string]int       // Directory to watch descriptor
+	limit   bool                 // Whether the watch limit was hit while adding a created directory
+	events  chan struct{}
+}
+
+type inotifyWatch struct {
+	path      string
+	recursive bool
+}
+
+// NewInotify creates an Inotify watcher without any registered directory.
+func NewInotify() (*Inotify, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+
+	w := &Inotify{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		watches: make(map[int]inotifyWatch),
+		paths:   make(map[string]int),
+		events:  make(chan struct{}, 1),
+	}
+
+	go w.readEvents()
+
+	return w, nil
+}
+
+// MaxUserWatches returns the maximum number of inotify watches of the current user.
+func MaxUserWatches() (int, error) {
+	data, err := os.ReadFile(maxUserWatchesPath)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// newNative returns the inotify backend.
+func newNative() (Watcher, error) {
+	return NewInotify()
+}
+
+// Events returns the channel receiving a value each time an inotify event is read.
+func (w *Inotify) Events() <-chan struct{} {
+	return w.events
+}
+
+// Watch adds an inotify watch on every given directory not watched yet. Directories that no longer
+// exist are skipped. ErrWatchLimit is returned once fs.inotify.max_user_watches is exhausted.
+func (w *Inotify) Watch(dirs map[string]bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limit {
+		return ErrWatchLimit
+	}
+
+	for dir, recursive := range dirs {
+		if err := w.addWatch(dir, recursive); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close removes all the watches and stops reading events.
+func (w *Inotify) Close() error {
+	return w.file.Close()
+}
+
+// addWatch registers a single directory. The caller must hold the lock.
+func (w *Inotify) addWatch(dir string, recursive bool) error {
+	if wd, ok := w.paths[dir]; ok {
+		if recursive && !w.watches[wd].recursive {
+			w.watches[wd] = inotifyWatch{path: dir, recursive: true}
+		}
+
+		return nil
+	}
+
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask|syscall.IN_ONLYDIR)
+	if err != nil {
+		switch {
+		case errors.Is(err, syscall.ENOSPC):
+			return ErrWatchLimit
+		case errors.Is(err, syscall.ENOENT), errors.Is(err, syscall.ENOTDIR):
+			return nil
+		}
+
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	w.watches[wd] = inotifyWatch{path: dir, recursive: recursive}
+	w.paths[dir] = wd
+
+	return nil
+}
+
+// addTree registers a directory created inside a recursive watch, along with its subdirectories.
+// The caller must hold the lock.
+func (w *Inotify) addTree(root string) {
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		if err = w.addWatch(path, true); errors.Is(err, ErrWatchLimit) {
+			w.limit = true
+			return filepath.SkipAll
+		}
+
+		return nil
+	})
+}
+
+// readEvents reads the inotify file descriptor until it is closed, keeps the watches in sync
+// with created and removed directories and emits one event per batch read.
+func (w *Inotify) readEvents() {
+	defer close(w.events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		w.mu.Lock()
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			offset = nameEnd
+
+			watched, ok := w.watches[int(event.Wd)]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.watches, int(event.Wd))
+				if ok && w.paths[watched.path] == int(event.Wd) {
+					delete(w.paths, watched.path)
+				}
+				continue
+			}
+
+			if !ok || !watched.recursive || event.Mask&syscall.IN_ISDIR == 0 || event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) == 0 {
+				continue
+			}
+
+			name := string(buf[nameStart:nameEnd])
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			w.addTree(filepath.Join(watched.path, name))
+		}
+		w.mu.Unlock()
+
+		notify(w.events)
+	}
+}
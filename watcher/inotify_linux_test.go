@@ -0,0 +1,214 @@
+//go:build linux
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectEvent(t *testing.T, w Watcher) {
+	t.Helper()
+
+	select {
+	case <-w.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an inotify event")
+	}
+}
+
+func drainEvents(w Watcher) {
+	for {
+		select {
+		case <-w.Events():
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestInotify_FileEvents(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewInotify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err = w.Watch(map[string]bool{dir: true}); err != nil {
+		t.Fatalf("Inotify.Watch() error = %v", err)
+	}
+
+	file := filepath.Join(dir, "services.yaml")
+	if err = os.WriteFile(file, []byte("services:"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w)
+	drainEvents(w)
+
+	if err = os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w)
+}
+
+func TestInotify_CreatedDirectories(t *testing.T) {
+	tests := []struct {
+		name      string
+		recursive bool
+		wantEvent bool
+	}{
+		{name: "Recursive directory", recursive: true, wantEvent: true},
+		{name: "Shallow directory", recursive: false, wantEvent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			w, err := NewInotify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			if err = w.Watch(map[string]bool{dir: tt.recursive}); err != nil {
+				t.Fatalf("Inotify.Watch() error = %v", err)
+			}
+
+			subDir := filepath.Join(dir, "Controller", "Admin")
+			if err = os.MkdirAll(subDir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			expectEvent(t, w)
+			drainEvents(w)
+
+			if err = os.WriteFile(filepath.Join(subDir, "DashboardController.php"), []byte("<?php"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-w.Events():
+				if !tt.wantEvent {
+					t.Error("unexpected event for a file created in an unwatched directory")
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.wantEvent {
+					t.Error("expected an event for a file created in a new directory")
+				}
+			}
+		})
+	}
+}
+
+func TestInotify_MissingDirectory(t *testing.T) {
+	w, err := NewInotify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err = w.Watch(map[string]bool{filepath.Join(t.TempDir(), "missing"): true}); err != nil {
+		t.Errorf("Inotify.Watch() error = %v, want nil", err)
+	}
+}
+
+func TestInotify_Close(t *testing.T) {
+	w, err := NewInotify()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatalf("Inotify.Close() error = %v", err)
+	}
+
+	select {
+	case _, ok := <-w.Events():
+		if ok {
+			t.Error("expected the events channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the events channel to be closed")
+	}
+}
+
+func TestWaitQuiet_Inotify(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewInotify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err = w.Watch(map[string]bool{dir: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep writing for a while: the quiet period must only start after the last write.
+	go func() {
+		for i := 0; i < 5; i++ {
+			_ = os.WriteFile(filepath.Join(dir, "composer.lock"), []byte{byte(i)}, 0o644)
+			time.Sleep(30 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	if !WaitQuiet(context.Background(), w, 100*time.Millisecond, time.Minute) {
+		t.Fatal("WaitQuiet() = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("WaitQuiet() returned after %v, before the writes stopped", elapsed)
+	}
+}
+
+func TestWaitQuiet_InotifyMaxWait(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewInotify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err = w.Watch(map[string]bool{dir: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A log rewritten more often than the period must not keep the wait going forever.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				_ = os.WriteFile(filepath.Join(dir, "dev.log"), []byte{byte(i)}, 0o644)
+			}
+		}
+	}()
+
+	start := time.Now()
+	if !WaitQuiet(context.Background(), w, 50*time.Millisecond, 200*time.Millisecond) {
+		t.Fatal("WaitQuiet() = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitQuiet() returned after %v, want about 200ms", elapsed)
+	}
+}
+
+func TestMaxUserWatches(t *testing.T) {
+	if _, err := os.Stat(maxUserWatchesPath); err != nil {
+		t.Skip(err)
+	}
+
+	if got, err := MaxUserWatches(); err != nil || got <= 0 {
+		t.Errorf("MaxUserWatches() = %v, %v, want a positive limit", got, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// MaxUserWatches reports that inotify watches are not limited on the current platform.
+func MaxUserWatches() (int, error) {
+	return 0, fmt.Errorf("inotify is not available on %s", runtime.GOOS)
+}
+
+// newNative reports that no event-driven backend exists for the current platform.
+func newNative() (Watcher, error) {
+	return nil, fmt.Errorf("event-driven watching is not supported on %s", runtime.GOOS)
+}
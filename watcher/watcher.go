@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+// ErrWatchLimit is returned when the kernel refuses to register more watches.
+var ErrWatchLimit = errors.New("inotify watch limit reached, raise fs.inotify.max_user_watches")
+
+// Watcher notifies that the watched files may have changed. An event does not tell what changed:
+// the receiver is expected to rescan the watch set and compare it with the previous one.
+type Watcher interface {
+	// Events returns the channel receiving a value each time something may have changed.
+	// Bursts of changes are coalesced into a single pending event.
+	Events() <-chan struct{}
+	// Watch registers the given directories, the value telling whether directories created
+	// inside them must be watched as well. Already registered directories are left untouched.
+	Watch(dirs map[string]bool) error
+	// Close stops the watcher and closes the Events channel.
+	Close() error
+}
+
+// New returns an event-driven watcher registered on the given directories. When polling is forced
+// by the configuration, or when the event-driven backend cannot be used, a Poller is returned instead
+// along with the error explaining why the fallback was needed. The returned watcher is always usable.
+func New(config structs.Config, dirs map[string]bool) (Watcher, error) {
+	if config.Poll {
+		return NewPoller(config.SleepTime), nil
+	}
+
+	native, err := newNative()
+	if err != nil {
+		return NewPoller(config.SleepTime), err
+	}
+
+	if err = native.Watch(dirs); err != nil {
+		_ = native.Close()
+		return NewPoller(config.SleepTime), err
+	}
+
+	return native, nil
+}
+
+// Poller is the fallback Watcher: it emits an event at a fixed interval so the watch set is rescanned periodically.
+type Poller struct {
+	events chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewPoller creates a Poller emitting an event every interval.
+func NewPoller(interval time.Duration) *Poller {
+	p := &Poller{
+		events: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				notify(p.events)
+			}
+		}
+	}()
+
+	return p
+}
+
+// Events returns the channel receiving the polling ticks.
+func (p *Poller) Events() <-chan struct{} {
+	return p.events
+}
+
+// Watch does nothing: the poller rescans everything on every tick.
+func (p *Poller) Watch(map[string]bool) error {
+	return nil
+}
+
+// Close stops the poller.
+func (p *Poller) Close() error {
+	p.once.Do(func() { close(p.done) })
+
+	return nil
+}
+
+// notify sends an event without blocking, dropping it if one is already pending.
+func notify(events chan struct{}) {
+	select {
+	case events <- struct{}{}:
+	default:
+	}
+}
+
+// WaitQuiet blocks until the watcher stayed silent for the given period. Each event restarts the period,
+// except for a Poller whose ticks carry no information: the period is then simply waited out. The whole wait
+// never exceeds maxWait, as a file rewritten more often than the period, even an excluded one sharing a
+// watched directory, would otherwise keep restarting it forever.
+// It returns false if ctx is canceled or the watcher closed in the meantime.
+func WaitQuiet(ctx context.Context, w Watcher, period, maxWait time.Duration) bool {
+	_, polling := w.(*Poller)
+
+	timer := time.NewTimer(period)
+	defer timer.Stop()
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-deadline.C:
+			return true
+		case _, ok := <-w.Events():
+			if !ok {
+				return false
+			}
+			if !polling {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(period)
+			}
+		}
+	}
+}
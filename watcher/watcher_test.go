@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lettland/cache-warmer/structs"
+)
+
+func TestPoller(t *testing.T) {
+	p := NewPoller(time.Millisecond)
+
+	select {
+	case <-p.Events():
+	case <-time.After(time.Second):
+		t.Fatal("expected a polling event")
+	}
+
+	if err := p.Watch(map[string]bool{"/nonexistent": true}); err != nil {
+		t.Errorf("Poller.Watch() error = %v, want nil", err)
+	}
+
+	_ = p.Close()
+	_ = p.Close()
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-p.Events():
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected the events channel to be closed")
+		}
+	}
+}
+
+func TestNew_Poll(t *testing.T) {
+	var config structs.Config
+	config.Init()
+	config.Poll = true
+
+	w, err := New(config, map[string]bool{t.TempDir(): true})
+	defer w.Close()
+
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	if _, ok := w.(*Poller); !ok {
+		t.Errorf("New() = %T, want *Poller", w)
+	}
+}
+
+func TestWaitQuiet_Poller(t *testing.T) {
+	p := NewPoller(time.Millisecond)
+	defer p.Close()
+
+	start := time.Now()
+	if !WaitQuiet(context.Background(), p, 20*time.Millisecond, time.Minute) {
+		t.Fatal("WaitQuiet() = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Errorf("WaitQuiet() waited %v, want about 20ms", elapsed)
+	}
+}
+
+func TestWaitQuiet_Closed(t *testing.T) {
+	p := NewPoller(time.Millisecond)
+	_ = p.Close()
+
+	if WaitQuiet(context.Background(), p, time.Second, time.Minute) {
+		t.Error("WaitQuiet() = true on a closed watcher, want false")
+	}
+}
+
+func TestWaitQuiet_Canceled(t *testing.T) {
+	p := NewPoller(time.Hour)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if WaitQuiet(ctx, p, time.Minute, time.Minute) {
+		t.Error("WaitQuiet() = true once canceled, want false")
+	}
+}